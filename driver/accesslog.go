@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// accessLogTimeLayout is the [time_local] format of Apache and nginx.
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogQuoted matches the inside of a quoted access log value, with
+// Apache's backslash escapes.
+const accessLogQuoted = `(?:[^"\\]|\\.)*`
+
+// accessLogFormat describes an access log layout. The regex captures the
+// groups named in accessLogFields, plus time, request and status.
+type accessLogFormat struct {
+	Name  string
+	Regex *regexp.Regexp
+}
+
+// Built-in access log layouts, selected with the parse-accesslog option.
+var accessLogFormats = map[string]*accessLogFormat{
+	// Common Log Format: %h %l %u %t "%r" %>s %b
+	"common": {
+		Name:  "common",
+		Regex: accessLogRegex(),
+	},
+	// Combined Log Format, also nginx's predefined "combined" format
+	"combined": {
+		Name:  "combined",
+		Regex: accessLogRegex("referer", "agent"),
+	},
+	// The "main" format of the default nginx.conf
+	"nginx-default": {
+		Name:  "nginx-default",
+		Regex: accessLogRegex("referer", "agent", "forwarded"),
+	},
+}
+
+// accessLogFields maps regex groups to journal fields.
+var accessLogFields = []struct {
+	Group string
+	Field string
+}{
+	{"client", "HTTP_CLIENT"},
+	{"status", "HTTP_STATUS"},
+	{"bytes", "HTTP_BYTES"},
+	{"referer", "HTTP_REFERER"},
+	{"agent", "HTTP_USER_AGENT"},
+	{"forwarded", "HTTP_X_FORWARDED_FOR"},
+}
+
+// accessLogRegex builds a Common Log Format regex followed by the named
+// quoted groups.
+func accessLogRegex(quoted ...string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`^(?P<client>\S+) \S+ \S+ \[(?P<time>[^\]]+)\] "(?P<request>` + accessLogQuoted + `)" (?P<status>[1-5]\d\d) (?P<bytes>\d+|-)`)
+	for _, name := range quoted {
+		b.WriteString(` "(?P<` + name + `>` + accessLogQuoted + `)"`)
+	}
+	b.WriteString(`\s*$`)
+	return regexp.MustCompile(b.String())
+}
+
+// accessLogEntry holds the parts of a parsed access log line.
+type accessLogEntry struct {
+	Status string
+	Time   time.Time // zero if unparseable
+	Fields []accessLogField
+}
+
+type accessLogField struct {
+	Name  string
+	Value string
+}
+
+// ParseAccessLog attempts to parse a line in the parse-accesslog format. The
+// client, request method and path, status, size, referer and user agent
+// become HTTP_* fields, omitting "-" values. Returns (nil, false) if access
+// log parsing is disabled or the line doesn't match, e.g. an nginx error
+// log line.
+func ParseAccessLog(cfg *Config, line []byte) (*accessLogEntry, bool) {
+	f := cfg.AccessLogFormat
+	if f == nil || len(line) == 0 {
+		return nil, false
+	}
+	m := f.Regex.FindSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	group := func(name string) string {
+		if i := f.Regex.SubexpIndex(name); i > 0 {
+			return string(m[i])
+		}
+		return ""
+	}
+
+	e := &accessLogEntry{Status: group("status")}
+	if t, err := time.Parse(accessLogTimeLayout, group("time")); err == nil {
+		e.Time = t
+	}
+	for _, af := range accessLogFields {
+		if v := group(af.Group); v != "" && v != "-" {
+			e.Fields = append(e.Fields, accessLogField{af.Field, v})
+		}
+	}
+	// Malformed requests (e.g. TLS to a plain port) have no method and path
+	if method, rest, ok := strings.Cut(group("request"), " "); ok {
+		path, _, _ := strings.Cut(rest, " ")
+		e.Fields = append(e.Fields, accessLogField{"HTTP_METHOD", method}, accessLogField{"HTTP_PATH", path})
+	}
+	return e, true
+}
+
+// statusPriority returns the access log priority for an HTTP status. An
+// exact status code wins over its class (e.g. 5xx).
+func (c *Config) statusPriority(status string) (Priority, string, bool) {
+	if pri, ok := c.AccessLogPriorities[status]; ok {
+		return pri, status, true
+	}
+	class := status[:1] + "xx"
+	pri, ok := c.AccessLogPriorities[class]
+	return pri, class, ok
+}
+
+// parseStatusPriorities parses an accesslog-priority value, a list of
+// STATUS:priority pairs where STATUS is a code (404) or class (4xx).
+func parseStatusPriorities(s string) (map[string]Priority, error) {
+	m := make(map[string]Priority)
+	for _, pair := range strings.Split(s, ",") {
+		status, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		status = strings.ToLower(strings.TrimSpace(status))
+		if !ok || !validStatusKey(status) {
+			return nil, fmt.Errorf("invalid entry %q: must be STATUS:priority, with STATUS like 404 or 4xx", pair)
+		}
+		pri, err := parsePriorityName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %q: %w", pair, err)
+		}
+		m[status] = pri
+	}
+	return m, nil
+}
+
+// validStatusKey reports whether s is an HTTP status code or class.
+func validStatusKey(s string) bool {
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	return s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9'
+}
@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		line       string
+		wantOK     bool
+		wantStatus string
+		wantFields map[string]string
+	}{
+		{
+			name:       "common",
+			format:     "common",
+			line:       `192.168.1.20 - frank [15/Jan/2024:11:30:45 +0100] "GET /index.html HTTP/1.1" 200 2326`,
+			wantOK:     true,
+			wantStatus: "200",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "192.168.1.20", "HTTP_METHOD": "GET", "HTTP_PATH": "/index.html",
+				"HTTP_STATUS": "200", "HTTP_BYTES": "2326",
+			},
+		},
+		{
+			name:       "combined",
+			format:     "combined",
+			line:       `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "POST /api/orders?id=1 HTTP/2.0" 502 157 "https://shop.example/" "Mozilla/5.0 (X11; Linux x86_64)"`,
+			wantOK:     true,
+			wantStatus: "502",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "10.0.0.1", "HTTP_METHOD": "POST", "HTTP_PATH": "/api/orders?id=1",
+				"HTTP_STATUS": "502", "HTTP_BYTES": "157", "HTTP_REFERER": "https://shop.example/",
+				"HTTP_USER_AGENT": "Mozilla/5.0 (X11; Linux x86_64)",
+			},
+		},
+		{
+			name:       "combined dashes omitted",
+			format:     "combined",
+			line:       `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "HEAD / HTTP/1.1" 304 - "-" "-"`,
+			wantOK:     true,
+			wantStatus: "304",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "10.0.0.1", "HTTP_METHOD": "HEAD", "HTTP_PATH": "/", "HTTP_STATUS": "304",
+			},
+		},
+		{
+			name:       "escaped quote",
+			format:     "combined",
+			line:       `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 5 "-" "curl \"x\""`,
+			wantOK:     true,
+			wantStatus: "200",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "10.0.0.1", "HTTP_METHOD": "GET", "HTTP_PATH": "/", "HTTP_STATUS": "200",
+				"HTTP_BYTES": "5", "HTTP_USER_AGENT": `curl \"x\"`,
+			},
+		},
+		{
+			name:       "malformed request",
+			format:     "combined",
+			line:       `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "\x16\x03\x01" 400 150 "-" "-"`,
+			wantOK:     true,
+			wantStatus: "400",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "10.0.0.1", "HTTP_STATUS": "400", "HTTP_BYTES": "150",
+			},
+		},
+		{
+			name:       "nginx default",
+			format:     "nginx-default",
+			line:       `172.17.0.1 - - [15/Jan/2024:10:30:45 +0000] "GET /health HTTP/1.1" 200 2 "-" "kube-probe/1.29" "203.0.113.7"`,
+			wantOK:     true,
+			wantStatus: "200",
+			wantFields: map[string]string{
+				"HTTP_CLIENT": "172.17.0.1", "HTTP_METHOD": "GET", "HTTP_PATH": "/health", "HTTP_STATUS": "200",
+				"HTTP_BYTES": "2", "HTTP_USER_AGENT": "kube-probe/1.29", "HTTP_X_FORWARDED_FOR": "203.0.113.7",
+			},
+		},
+		{
+			name:   "nginx error log",
+			format: "combined",
+			line:   `2024/01/15 10:30:45 [error] 29#29: *1 connect() failed (111: Connection refused) while connecting to upstream`,
+		},
+		{
+			name:   "combined line with common format",
+			format: "common",
+			line:   `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 5 "-" "curl/8.0"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mustConfig(t, map[string]string{"parse-accesslog": tt.format})
+			e, ok := ParseAccessLog(cfg, []byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if e.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", e.Status, tt.wantStatus)
+			}
+			if want := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC); !e.Time.Equal(want) {
+				t.Errorf("time = %v, want %v", e.Time, want)
+			}
+			got := make(map[string]string)
+			for _, f := range e.Fields {
+				got[f.Name] = f.Value
+			}
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestStatusPriority(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{})
+	for status, want := range map[string]Priority{"500": PriErr, "503": PriErr, "404": PriWarning} {
+		if pri, _, ok := cfg.statusPriority(status); !ok || pri != want {
+			t.Errorf("statusPriority(%s) = %d, %v, want %d", status, pri, ok, want)
+		}
+	}
+	if _, _, ok := cfg.statusPriority("200"); ok {
+		t.Error("200 should have no status priority")
+	}
+
+	cfg = mustConfig(t, map[string]string{"accesslog-priority": "5xx:crit, 404:info, 4XX:notice, 2xx:debug"})
+	tests := []struct {
+		status   string
+		wantPri  Priority
+		wantRule string
+	}{
+		{"502", PriCrit, "5xx"},
+		{"404", PriInfo, "404"},
+		{"401", PriNotice, "4xx"},
+		{"204", PriDebug, "2xx"},
+	}
+	for _, tt := range tests {
+		pri, rule, ok := cfg.statusPriority(tt.status)
+		if !ok || pri != tt.wantPri || rule != tt.wantRule {
+			t.Errorf("statusPriority(%s) = %d, %q, %v, want %d, %q", tt.status, pri, rule, ok, tt.wantPri, tt.wantRule)
+		}
+	}
+	if _, _, ok := cfg.statusPriority("302"); ok {
+		t.Error("302 should have no status priority")
+	}
+}
@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// truncatedMarker is appended to field values cut by max-field-bytes.
+const truncatedMarker = "...[truncated]"
+
+// Field tiers for max-fields, in the order fields are kept.
+const (
+	fieldTierBase      = iota // container metadata, timestamps, bookkeeping fields
+	fieldTierMapped           // json-field-map
+	fieldTierPipeline         // derived, access log and json-prefix-field fields
+	fieldTierExtracted        // field-* extractors
+	fieldTierJSON             // prefixed JSON fields
+	fieldTierRaw              // RAW_MESSAGE
+)
+
+// bookkeepingFieldNames are the fields the driver adds to describe how an
+// entry was processed.
+var bookkeepingFieldNames = map[string]bool{
+	"SYSLOG_TIMESTAMP":          true,
+	"SOURCE_REALTIME_TIMESTAMP": true,
+	"TIMESTAMP_SKEW_USEC":       true,
+	"PRIORITY_SOURCE":           true,
+	"PRIORITY_RULE":             true,
+	"SAMPLE_RATE":               true,
+	"REPEAT_COUNT":              true,
+	"BATCH_ID":                  true,
+}
+
+// applyFieldBudgets enforces max-fields and max-field-bytes on the fields of
+// a single entry. Each value of a repeated field counts as a field. Excess
+// fields are dropped by tier, then by name, so the same entry always keeps
+// the same fields. The number of dropped and truncated values is recorded in
+// FIELDS_DROPPED and FIELDS_TRUNCATED, which count towards max-fields.
+func (w *journalWriter) applyFieldBudgets(vars Fields, msg *mergedMessage, extracted Fields) {
+	reserved := 0 // FIELDS_TRUNCATED
+	if w.cfg.MaxFieldBytes > 0 && hasLongValue(vars, w.cfg.MaxFieldBytes) {
+		reserved = 1
+	}
+	if w.cfg.MaxFields > 0 && countValues(vars)+reserved > w.cfg.MaxFields {
+		names := make([]string, 0, len(vars))
+		tiers := make(map[string]int, len(vars))
+		for name := range vars {
+			names = append(names, name)
+			tiers[name] = w.fieldTier(name, msg, extracted)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if tiers[names[i]] != tiers[names[j]] {
+				return tiers[names[i]] < tiers[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		limit := w.cfg.MaxFields - reserved - 1 // FIELDS_DROPPED
+		kept, dropped := 0, 0
+		for _, name := range names {
+			n := len(vars[name])
+			if kept+n > limit {
+				delete(vars, name)
+				dropped += n
+				continue
+			}
+			kept += n
+		}
+		vars.Set("FIELDS_DROPPED", strconv.Itoa(dropped))
+	}
+
+	if w.cfg.MaxFieldBytes > 0 {
+		truncated := 0
+		for name, values := range vars {
+			var cut []string // copy on write, values may be shared
+			for i, v := range values {
+				if len(v) <= w.cfg.MaxFieldBytes {
+					continue
+				}
+				if cut == nil {
+					cut = append([]string(nil), values...)
+				}
+				cut[i] = truncateValue(v, w.cfg.MaxFieldBytes)
+				truncated++
+			}
+			if cut != nil {
+				vars[name] = cut
+			}
+		}
+		if truncated > 0 {
+			vars.Set("FIELDS_TRUNCATED", strconv.Itoa(truncated))
+		}
+	}
+}
+
+// hasLongValue reports whether any value in f is longer than max bytes.
+func hasLongValue(f Fields, max int) bool {
+	for _, values := range f {
+		for _, v := range values {
+			if len(v) > max {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func countValues(f Fields) int {
+	n := 0
+	for _, v := range f {
+		n += len(v)
+	}
+	return n
+}
+
+// fieldTier returns the tier of a field name. A name set by several sources
+// gets the highest-priority tier.
+func (w *journalWriter) fieldTier(name string, msg *mergedMessage, extracted Fields) int {
+	if _, ok := w.baseVars[name]; ok || bookkeepingFieldNames[name] {
+		return fieldTierBase
+	}
+	if w.mappedNames[name] {
+		return fieldTierMapped
+	}
+	if name == "RAW_MESSAGE" {
+		return fieldTierRaw
+	}
+	if _, ok := msg.Fields[name]; ok {
+		return fieldTierPipeline
+	}
+	if _, ok := extracted[name]; ok {
+		return fieldTierExtracted
+	}
+	return fieldTierJSON
+}
+
+// truncateValue cuts v to at most max bytes including truncatedMarker,
+// without splitting a UTF-8 sequence.
+func truncateValue(v string, max int) string {
+	n := max - len(truncatedMarker)
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n] + truncatedMarker
+}
@@ -0,0 +1,146 @@
+package driver
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJournalWriterMaxFields(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"labels":          "app",
+		"json-field-map":  "trace_id:TRACE_ID",
+		"field-USER":      `user=(\w+)`,
+		"max-fields":      "9",
+		"max-field-bytes": "40",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{
+		ContainerID:     "abcdef123456789012345678",
+		ContainerName:   "/web",
+		ContainerLabels: map[string]string{"app": "shop"},
+	})
+
+	var lastVars Fields
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), func(_ string, _ Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	// 7 base fields (CONTAINER_ID, CONTAINER_ID_FULL, CONTAINER_NAME,
+	// IMAGE_NAME, CONTAINER_TAG, SYSLOG_IDENTIFIER, APP) + SYSLOG_TIMESTAMP +
+	// FIELDS_DROPPED leave no room for the mapped, extracted and JSON fields.
+	jsonFields := Fields{"trace_id": {"t1"}, "a": {"1"}, "b": {"2"}}
+	msg := mergedMessage{Line: []byte("x"), TimeNano: 1000000000}
+	w.Write(msg, PriInfo, []byte("login user=bob"), jsonFields)
+	for _, name := range []string{"APP", "SYSLOG_TIMESTAMP", "CONTAINER_ID"} {
+		if _, ok := lastVars[name]; !ok {
+			t.Errorf("base field %s was dropped", name)
+		}
+	}
+	for _, name := range []string{"TRACE_ID", "USER", "JSON_A", "JSON_B"} {
+		if _, ok := lastVars[name]; ok {
+			t.Errorf("%s should be dropped", name)
+		}
+	}
+	if lastVars.Get("FIELDS_DROPPED") != "4" {
+		t.Errorf("FIELDS_DROPPED = %q, want 4", lastVars.Get("FIELDS_DROPPED"))
+	}
+	if n := countValues(lastVars); n > cfg.MaxFields {
+		t.Errorf("got %d fields, want at most %d", n, cfg.MaxFields)
+	}
+
+	// Mapped before extracted before JSON, then by name
+	cfg.MaxFields = 12
+	jsonFields["c"] = []string{"3"}
+	w.Write(msg, PriInfo, []byte("login user=bob"), jsonFields)
+	for _, name := range []string{"TRACE_ID", "USER", "JSON_A"} {
+		if _, ok := lastVars[name]; !ok {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+	for _, name := range []string{"JSON_B", "JSON_C"} {
+		if _, ok := lastVars[name]; ok {
+			t.Errorf("%s should be dropped", name)
+		}
+	}
+	if lastVars.Get("FIELDS_DROPPED") != "2" {
+		t.Errorf("FIELDS_DROPPED = %q, want 2", lastVars.Get("FIELDS_DROPPED"))
+	}
+	if n := countValues(lastVars); n != cfg.MaxFields {
+		t.Errorf("got %d fields, want %d", n, cfg.MaxFields)
+	}
+
+	// Bookkeeping fields are base, pipeline fields come after mapped ones
+	// and before extracted ones, RAW_MESSAGE comes last
+	pipelineMsg := msg
+	pipelineMsg.Fields = map[string]string{"REPEAT_COUNT": "2", "ROUTE": "GET /", "RAW_MESSAGE": "raw"}
+	w.Write(pipelineMsg, PriInfo, []byte("login user=bob"), jsonFields)
+	for _, name := range []string{"REPEAT_COUNT", "TRACE_ID", "ROUTE"} {
+		if _, ok := lastVars[name]; !ok {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+	for _, name := range []string{"USER", "JSON_A", "JSON_B", "JSON_C", "RAW_MESSAGE"} {
+		if _, ok := lastVars[name]; ok {
+			t.Errorf("%s should be dropped", name)
+		}
+	}
+
+	// Within budget: no counters
+	cfg.MaxFields = 100
+	w.Write(msg, PriInfo, []byte("x"), nil)
+	if _, ok := lastVars["FIELDS_DROPPED"]; ok {
+		t.Error("FIELDS_DROPPED should be absent within budget")
+	}
+	if _, ok := lastVars["FIELDS_TRUNCATED"]; ok {
+		t.Error("FIELDS_TRUNCATED should be absent within budget")
+	}
+
+	// Oversized values are truncated
+	w.Write(msg, PriInfo, []byte("x"), Fields{"payload": {strings.Repeat("x", 100)}, "small": {"ok"}})
+	if got := lastVars.Get("JSON_PAYLOAD"); len(got) != 40 || !strings.HasSuffix(got, truncatedMarker) {
+		t.Errorf("JSON_PAYLOAD = %q, want 40 bytes ending in marker", got)
+	}
+	if lastVars.Get("JSON_SMALL") != "ok" {
+		t.Errorf("JSON_SMALL = %q", lastVars.Get("JSON_SMALL"))
+	}
+	if lastVars.Get("FIELDS_TRUNCATED") != "1" {
+		t.Errorf("FIELDS_TRUNCATED = %q, want 1", lastVars.Get("FIELDS_TRUNCATED"))
+	}
+
+	// Both counters fit within max-fields
+	cfg.MaxFields = 11
+	w.Write(msg, PriInfo, []byte("x"), Fields{"payload": {strings.Repeat("x", 100)}, "small": {"ok"}, "tiny": {"ok"}})
+	for _, name := range []string{"JSON_SMALL", "JSON_TINY"} {
+		if _, ok := lastVars[name]; ok {
+			t.Errorf("%s should be dropped", name)
+		}
+	}
+	if lastVars.Get("FIELDS_DROPPED") != "2" || lastVars.Get("FIELDS_TRUNCATED") != "1" {
+		t.Errorf("FIELDS_DROPPED = %q, FIELDS_TRUNCATED = %q, want 2 and 1",
+			lastVars.Get("FIELDS_DROPPED"), lastVars.Get("FIELDS_TRUNCATED"))
+	}
+	if n := countValues(lastVars); n != cfg.MaxFields {
+		t.Errorf("got %d fields, want %d", n, cfg.MaxFields)
+	}
+}
+
+func TestTruncateValue(t *testing.T) {
+	tests := []struct {
+		v    string
+		max  int
+		want string
+	}{
+		{strings.Repeat("a", 30), 20, "aaaaaa" + truncatedMarker},
+		{"ééééééééééé", 20, "ééé" + truncatedMarker}, // 6 bytes fit exactly
+		{"aéééééééééé", 20, "aéé" + truncatedMarker}, // don't split é
+	}
+	for _, tt := range tests {
+		if got := truncateValue(tt.v, tt.max); got != tt.want {
+			t.Errorf("truncateValue(%q, %d) = %q, want %q", tt.v, tt.max, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package driver
 
 import (
 	"fmt"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -48,34 +51,127 @@ type Config struct {
 	MultilineMaxLines int
 	MultilineMaxBytes int
 	MultilineSep      string
+	MultilineStart    *regexp.Regexp // Starts a record merged until MultilineEnd
+	MultilineEnd      *regexp.Regexp // nil = no record merging
 
 	// Timestamp stripping
 	StripTimestamp         bool
-	StripTimestampPatterns []*regexp.Regexp // compiled; nil if disabled
+	StripTimestampPatterns []timestampPattern // user patterns, tried first
+	StripTimestampScanners []timestampScanner // built-in formats
+	TimestampFromApp       bool               // Use the stripped timestamp as entry time
+	TimestampLocation      *time.Location     // Zone for timestamps without offset
+	TimestampMaxSkew       time.Duration      // Max distance from Docker time; 0 = unlimited
 
 	// Priority
 	PriorityPrefix        bool
 	PriorityDefaultStdout Priority
 	PriorityDefaultStderr Priority
-	PriorityMatchers      []priorityMatcher // ordered emerg..debug
+	PriorityMatchers      []priorityMatcher   // ordered emerg..debug
+	PriorityExplain       bool                // Add PRIORITY_SOURCE/PRIORITY_RULE fields
+	LevelMap              map[string]Priority // Custom level names (lowercase)
+	LevelScheme           string              // Built-in numeric level scheme
 
 	// JSON parsing
-	ParseJSON       bool
-	JSONLevelKeys   []string // Keys to check for level/severity
-	JSONMessageKeys []string // Keys to check for message body
+	ParseJSON         bool
+	JSONLevelKeys     []string          // Keys to check for level/severity
+	JSONMessageKeys   []string          // Keys to check for message body
+	JSONTimeKeys      []string          // Keys to check for entry time; nil = disabled
+	JSONAppendKeys    []string          // Keys appended to the message body, in order
+	JSONFlatten       bool              // Flatten nested objects into dotted keys
+	JSONFlattenDepth  int               // Max key path length when flattening
+	JSONFieldMap      map[string]string // JSON key -> journal field name
+	JSONFieldsInclude []string          // Globs of JSON keys to keep; nil = all
+	JSONFieldsExclude []string          // Globs of JSON keys to drop
+	JSONFieldPrefix   string            // Prefix for unmapped JSON fields
+	JSONPrefixRegex   *regexp.Regexp    // Text before the JSON object; nil = none
+	JSONPrefixField   string            // Field for the text prefix; "" = dropped
+	JSONUnwrapString  bool              // Decode JSON strings containing JSON
+	JSONSplitArrays   bool              // Write each object of a JSON array as an entry
+
+	// Logfmt parsing (uses the JSON key and field options)
+	ParseLogfmt bool
+
+	// XML event parsing (uses the JSON field options)
+	XMLFormat *xmlLogFormat // nil = disabled
+
+	// Access log parsing
+	AccessLogFormat     *accessLogFormat    // nil = disabled
+	AccessLogPriorities map[string]Priority // Status code or class (5xx) to priority
 
 	// Field extraction
-	FieldExtractors []fieldExtractor // Regex patterns to extract custom fields
+	FieldExtractors []fieldExtractor            // Regex patterns to extract custom fields
+	FieldRegexes    []*regexp.Regexp            // Regexes whose named groups are fields (field-regex, grok-pattern)
+	FieldTransforms map[string][]fieldTransform // Value transforms by journal field name
+
+	// Raw line preservation
+	KeepRaw         bool // Store the unprocessed line in RAW_MESSAGE
+	KeepRawOnChange bool // Only if processing changed the message
+	KeepRawMaxBytes int  // Longer raw lines are truncated
+
+	// Per-entry field budgets (0 = unlimited)
+	MaxFieldBytes int // Longer field values are truncated
+	MaxFields     int // Excess fields are dropped
+
+	// Templates over parsed fields and container metadata
+	MessageTemplate *template.Template // Replaces MESSAGE; nil = disabled
+	DerivedFields   []derivedField     // Computed fields, sorted by name
+
+	// Deduplication
+	DedupeWindow time.Duration // 0 = disabled
+	DedupeFuzzy  bool          // Mask digits, hex IDs and UUIDs before comparing
+
+	// Sampling
+	SampleRates map[Priority]float64 // Fraction of entries to keep, per priority
+	SampleKey   string               // Field to key consistent sampling on; empty = random
 }
 
 type priorityMatcher struct {
 	Priority Priority
 	Regex    *regexp.Regexp
+	Option   string // Option name, e.g. priority-match-err
 }
 
 type fieldExtractor struct {
 	FieldName string
 	Regex     *regexp.Regexp
+	All       bool   // Extract every match as a repeated field
+	Source    string // Input: message, raw, json, label or env
+	SourceKey string // JSON key, label or env var name for json, label and env
+}
+
+// fieldSource holds the inputs a field extractor can read from.
+type fieldSource struct {
+	Message string            // Processed message
+	Raw     string            // Original line, before parsing and stripping
+	JSON    Fields            // Parsed JSON, logfmt or XML fields by key
+	Labels  map[string]string // Container labels
+	Env     map[string]string // Container environment
+}
+
+// inputs returns the values the extractor reads from src.
+func (e *fieldExtractor) inputs(src *fieldSource) []string {
+	switch e.Source {
+	case "raw":
+		return []string{src.Raw}
+	case "json":
+		return src.JSON[e.SourceKey]
+	case "label":
+		if v, ok := src.Labels[e.SourceKey]; ok {
+			return []string{v}
+		}
+	case "env":
+		if v, ok := src.Env[e.SourceKey]; ok {
+			return []string{v}
+		}
+	default:
+		return []string{src.Message}
+	}
+	return nil
+}
+
+type derivedField struct {
+	FieldName string
+	Template  *template.Template
 }
 
 // known option keys
@@ -103,22 +199,69 @@ var knownOpts = map[string]bool{
 	"priority-match-notice":   true,
 	"priority-match-info":     true,
 	"priority-match-debug":    true,
+	"priority-explain":        true,
+	"level-map":               true,
+	"level-scheme":            true,
+
+	"strip-timestamp":             true,
+	"strip-timestamp-regex":       true,
+	"strip-timestamp-formats":     true,
+	"strip-timestamp-extra-regex": true,
+	"timestamp-source":            true,
+	"timestamp-timezone":          true,
+	"timestamp-max-skew":          true,
+
+	"parse-json":          true,
+	"parse-logfmt":        true,
+	"parse-xml":           true,
+	"parse-accesslog":     true,
+	"accesslog-priority":  true,
+	"json-schema":         true,
+	"json-level-keys":     true,
+	"json-message-keys":   true,
+	"json-time-keys":      true,
+	"json-append-keys":    true,
+	"json-flatten":        true,
+	"json-flatten-depth":  true,
+	"json-field-map":      true,
+	"json-fields-include": true,
+	"json-fields-exclude": true,
+	"json-field-prefix":   true,
+	"json-prefix-regex":   true,
+	"json-prefix-field":   true,
+	"json-unwrap-string":  true,
+	"json-split-arrays":   true,
+
+	"message-template": true,
+
+	"keep-raw":           true,
+	"keep-raw-max-bytes": true,
+
+	"max-field-bytes": true,
+	"max-fields":      true,
+
+	"hmac-key-file": true,
 
-	"strip-timestamp":       true,
-	"strip-timestamp-regex": true,
+	"dedupe-window": true,
+	"dedupe-fuzzy":  true,
 
-	"parse-json":        true,
-	"json-level-keys":   true,
-	"json-message-keys": true,
+	"sample-mode": true,
+	"sample-key":  true,
 }
 
 // ParseConfig validates and parses a map of log-opt key/value pairs.
 func ParseConfig(opts map[string]string) (*Config, error) {
 	for key := range opts {
-		if !knownOpts[key] && !strings.HasPrefix(key, "field-") {
+		if !knownOpts[key] && !strings.HasPrefix(key, "field-") && !strings.HasPrefix(key, "sample-") &&
+			!strings.HasPrefix(key, "strip-timestamp-extra-regex-") && !strings.HasPrefix(key, "derived-field-") &&
+			!isGrokPatternKey(key) && !strings.HasPrefix(key, "grok-define-") {
 			return nil, fmt.Errorf("unknown log-opt %q", key)
 		}
 	}
+	opts, err := applyJSONSchema(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
 		MultilineTimeout:      10 * time.Millisecond,
@@ -128,6 +271,11 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		PriorityPrefix:        true,
 		PriorityDefaultStdout: PriInfo,
 		PriorityDefaultStderr: PriErr,
+		TimestampLocation:     time.UTC,
+		TimestampMaxSkew:      time.Hour,
+		JSONFlattenDepth:      5,
+		JSONFieldPrefix:       "JSON_",
+		KeepRawMaxBytes:       65536,
 	}
 
 	// Tag
@@ -265,9 +413,34 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		cfg.PriorityMatchers = append(cfg.PriorityMatchers, priorityMatcher{
 			Priority: mk.pri,
 			Regex:    r,
+			Option:   mk.opt,
 		})
 	}
 
+	// Priority explain
+	if v, ok := opts["priority-explain"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority-explain %q: must be true or false", v)
+		}
+		cfg.PriorityExplain = b
+	}
+
+	// Level mapping
+	if v, ok := opts["level-map"]; ok && v != "" {
+		m, err := parseLevelMap(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level-map: %w", err)
+		}
+		cfg.LevelMap = m
+	}
+	if v, ok := opts["level-scheme"]; ok && v != "" {
+		if _, ok := levelSchemes[v]; !ok {
+			return nil, fmt.Errorf("invalid level-scheme %q: must be pino, syslog, dotnet or otel", v)
+		}
+		cfg.LevelScheme = v
+	}
+
 	// Timestamp stripping
 	if v, ok := opts["strip-timestamp"]; ok {
 		b, err := strconv.ParseBool(v)
@@ -277,21 +450,78 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		cfg.StripTimestamp = b
 	}
 	if cfg.StripTimestamp {
+		var formats []timestampFormat
+
+		// Extra user patterns (strip-timestamp-extra-regex[-N]), tried first
+		var extraKeys []string
+		for key := range opts {
+			if key == "strip-timestamp-extra-regex" || strings.HasPrefix(key, "strip-timestamp-extra-regex-") {
+				extraKeys = append(extraKeys, key)
+			}
+		}
+		sortOptionKeys(extraKeys)
+		for _, key := range extraKeys {
+			if opts[key] != "" {
+				formats = append(formats, timestampFormat{Name: key, Pattern: opts[key]})
+			}
+		}
+
 		if v, ok := opts["strip-timestamp-regex"]; ok && v != "" {
-			// User-provided single pattern
-			patterns, err := compileTimestampPatterns([]string{v})
-			if err != nil {
-				return nil, fmt.Errorf("invalid strip-timestamp-regex %q: %w", v, err)
+			// User-provided single pattern replaces the built-ins
+			if _, ok := opts["strip-timestamp-formats"]; ok {
+				return nil, fmt.Errorf("strip-timestamp-formats cannot be combined with strip-timestamp-regex")
 			}
-			cfg.StripTimestampPatterns = patterns
-		} else {
-			// Use built-in patterns
-			patterns, err := compileTimestampPatterns(defaultTimestampPatterns)
+			formats = append(formats, timestampFormat{Name: "strip-timestamp-regex", Pattern: v})
+		} else if v, ok := opts["strip-timestamp-formats"]; ok && v != "" {
+			// Selected built-in formats
+			names := strings.Split(v, ",")
+			for i := range names {
+				names[i] = strings.TrimSpace(names[i])
+			}
+			scanners, err := selectTimestampScanners(names)
 			if err != nil {
-				return nil, fmt.Errorf("compiling default timestamp patterns: %w", err)
+				return nil, fmt.Errorf("invalid strip-timestamp-formats: %w", err)
 			}
-			cfg.StripTimestampPatterns = patterns
+			cfg.StripTimestampScanners = scanners
+		} else {
+			// Use all built-in formats
+			cfg.StripTimestampScanners = builtinTimestampScanners
 		}
+
+		patterns, err := compileTimestampPatterns(formats)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %w", err)
+		}
+		cfg.StripTimestampPatterns = patterns
+	}
+
+	// Timestamp source (docker or app)
+	switch v := opts["timestamp-source"]; v {
+	case "", "docker":
+	case "app":
+		if !cfg.StripTimestamp {
+			return nil, fmt.Errorf("timestamp-source=app requires strip-timestamp=true")
+		}
+		cfg.TimestampFromApp = true
+	default:
+		return nil, fmt.Errorf("invalid timestamp-source %q: must be docker or app", v)
+	}
+	if v, ok := opts["timestamp-timezone"]; ok && v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp-timezone %q: %w", v, err)
+		}
+		cfg.TimestampLocation = loc
+	}
+	if v, ok := opts["timestamp-max-skew"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp-max-skew %q: %w", v, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("timestamp-max-skew must not be negative, got %v", d)
+		}
+		cfg.TimestampMaxSkew = d
 	}
 
 	// Parse JSON options
@@ -302,6 +532,38 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		}
 		cfg.ParseJSON = b
 	}
+	if v, ok := opts["parse-logfmt"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parse-logfmt %q: must be true or false", v)
+		}
+		cfg.ParseLogfmt = b
+	}
+	if v, ok := opts["parse-xml"]; ok && v != "" {
+		f, ok := xmlLogFormats[v]
+		if !ok {
+			return nil, fmt.Errorf("invalid parse-xml %q: must be log4j or log4net", v)
+		}
+		cfg.XMLFormat = f
+		cfg.MultilineStart = f.Start
+		cfg.MultilineEnd = f.End
+	}
+	if v, ok := opts["parse-accesslog"]; ok && v != "" {
+		f, ok := accessLogFormats[v]
+		if !ok {
+			return nil, fmt.Errorf("invalid parse-accesslog %q: must be common, combined or nginx-default", v)
+		}
+		cfg.AccessLogFormat = f
+	}
+	statusPriorities := "5xx:err,4xx:warning"
+	if v, ok := opts["accesslog-priority"]; ok && v != "" {
+		statusPriorities = v
+	}
+	priorities, err := parseStatusPriorities(statusPriorities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid accesslog-priority: %w", err)
+	}
+	cfg.AccessLogPriorities = priorities
 
 	// JSON level keys (comma-separated, defaults to "level,severity,log_level")
 	if v, ok := opts["json-level-keys"]; ok && v != "" {
@@ -323,9 +585,178 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		cfg.JSONMessageKeys = []string{"message", "msg", "log"}
 	}
 
+	// JSON time keys (comma-separated, no default)
+	if v, ok := opts["json-time-keys"]; ok && v != "" {
+		cfg.JSONTimeKeys = strings.Split(v, ",")
+		for i := range cfg.JSONTimeKeys {
+			cfg.JSONTimeKeys[i] = strings.TrimSpace(cfg.JSONTimeKeys[i])
+		}
+	}
+
+	// JSON append keys (comma-separated, no default)
+	if v, ok := opts["json-append-keys"]; ok && v != "" {
+		cfg.JSONAppendKeys = strings.Split(v, ",")
+		for i := range cfg.JSONAppendKeys {
+			cfg.JSONAppendKeys[i] = strings.TrimSpace(cfg.JSONAppendKeys[i])
+		}
+	}
+
+	// JSON flattening
+	if v, ok := opts["json-flatten"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-flatten %q: must be true or false", v)
+		}
+		cfg.JSONFlatten = b
+	}
+	if v, ok := opts["json-flatten-depth"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid json-flatten-depth %q: must be a positive integer", v)
+		}
+		cfg.JSONFlattenDepth = n
+	}
+
+	// JSON field mapping (key:FIELD pairs)
+	if v, ok := opts["json-field-map"]; ok && v != "" {
+		cfg.JSONFieldMap = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			key, field, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			key, field = strings.TrimSpace(key), strings.TrimSpace(field)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid json-field-map entry %q: must be key:FIELD", pair)
+			}
+			if !validFieldName(field) {
+				return nil, fmt.Errorf("invalid json-field-map entry %q: %q is not a valid journal field name", pair, field)
+			}
+			if reservedFieldNames[field] {
+				return nil, fmt.Errorf("invalid json-field-map entry %q: %s is set by the driver", pair, field)
+			}
+			cfg.JSONFieldMap[key] = field
+		}
+	}
+
+	// JSON field include/exclude globs
+	for _, opt := range []string{"json-fields-include", "json-fields-exclude"} {
+		v, ok := opts[opt]
+		if !ok || v == "" {
+			continue
+		}
+		globs := strings.Split(v, ",")
+		for i := range globs {
+			globs[i] = strings.TrimSpace(globs[i])
+			if _, err := path.Match(globs[i], ""); err != nil {
+				return nil, fmt.Errorf("invalid %s pattern %q: %w", opt, globs[i], err)
+			}
+		}
+		if opt == "json-fields-include" {
+			cfg.JSONFieldsInclude = globs
+		} else {
+			cfg.JSONFieldsExclude = globs
+		}
+	}
+
+	// JSON field prefix
+	if v, ok := opts["json-field-prefix"]; ok {
+		if v != "" && !validFieldName(v) {
+			return nil, fmt.Errorf("invalid json-field-prefix %q: must be uppercase letters, digits or underscores", v)
+		}
+		cfg.JSONFieldPrefix = v
+	}
+
+	// JSON text prefix and string unwrapping
+	if v, ok := opts["json-prefix-regex"]; ok && v != "" {
+		r, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-prefix-regex %q: %w", v, err)
+		}
+		cfg.JSONPrefixRegex = r
+	}
+	if v, ok := opts["json-prefix-field"]; ok && v != "" {
+		if cfg.JSONPrefixRegex == nil {
+			return nil, fmt.Errorf("json-prefix-field requires json-prefix-regex")
+		}
+		if !validFieldName(v) {
+			return nil, fmt.Errorf("invalid json-prefix-field %q: not a valid journal field name", v)
+		}
+		if reservedFieldNames[v] {
+			return nil, fmt.Errorf("invalid json-prefix-field %q: %s is set by the driver", v, v)
+		}
+		cfg.JSONPrefixField = v
+	}
+	if v, ok := opts["json-unwrap-string"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-unwrap-string %q: must be true or false", v)
+		}
+		cfg.JSONUnwrapString = b
+	}
+
+	// JSON array splitting
+	if v, ok := opts["json-split-arrays"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-split-arrays %q: must be true or false", v)
+		}
+		if b && !cfg.ParseJSON {
+			return nil, fmt.Errorf("json-split-arrays=true requires parse-json=true")
+		}
+		cfg.JSONSplitArrays = b
+	}
+
+	// Dedupe window
+	if v, ok := opts["dedupe-window"]; ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dedupe-window %q: %w", v, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("dedupe-window must not be negative, got %v", d)
+		}
+		cfg.DedupeWindow = d
+	}
+	if v, ok := opts["dedupe-fuzzy"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dedupe-fuzzy %q: must be true or false", v)
+		}
+		cfg.DedupeFuzzy = b
+	}
+
+	// Sample rates (sample-PRIORITY options)
+	for key, v := range opts {
+		if !strings.HasPrefix(key, "sample-") || knownOpts[key] {
+			continue
+		}
+		p, err := parsePriorityName(strings.TrimPrefix(key, "sample-"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid %s %q: must be a number between 0 and 1", key, v)
+		}
+		if cfg.SampleRates == nil {
+			cfg.SampleRates = make(map[Priority]float64)
+		}
+		cfg.SampleRates[p] = rate
+	}
+
+	// Sample mode (random or consistent)
+	switch mode := opts["sample-mode"]; mode {
+	case "", "random":
+	case "consistent":
+		cfg.SampleKey = strings.TrimSpace(opts["sample-key"])
+		if cfg.SampleKey == "" {
+			return nil, fmt.Errorf("sample-mode=consistent requires sample-key")
+		}
+	default:
+		return nil, fmt.Errorf("invalid sample-mode %q: must be random or consistent", mode)
+	}
+
 	// Field extractors (field-FIELDNAME options)
 	for key, pattern := range opts {
-		if !strings.HasPrefix(key, "field-") {
+		if !strings.HasPrefix(key, "field-") || isFieldRegexKey(key) || isFieldOptionKey(key) {
 			continue
 		}
 		fieldName := strings.TrimPrefix(key, "field-")
@@ -343,15 +774,248 @@ func ParseConfig(opts map[string]string) (*Config, error) {
 		if r.NumSubexp() == 0 {
 			return nil, fmt.Errorf("invalid field extractor %q pattern %q: must contain at least one capture group ()", key, pattern)
 		}
+		var all bool
+		switch v := opts[key+"-match"]; v {
+		case "", "first":
+		case "all":
+			all = true
+		default:
+			return nil, fmt.Errorf("invalid %s-match %q: must be first or all", key, v)
+		}
+		source, sourceKey, err := parseFieldSource(key, opts[key+"-source"])
+		if err != nil {
+			return nil, err
+		}
 		cfg.FieldExtractors = append(cfg.FieldExtractors, fieldExtractor{
 			FieldName: fieldName,
 			Regex:     r,
+			All:       all,
+			Source:    source,
+			SourceKey: sourceKey,
 		})
 	}
+	for key := range opts {
+		if !strings.HasPrefix(key, "field-") || !isFieldOptionKey(key) || strings.HasSuffix(key, "-transform") {
+			continue
+		}
+		base := key[:strings.LastIndexByte(key, '-')]
+		if _, ok := opts[base]; !ok || isFieldRegexKey(base) {
+			return nil, fmt.Errorf("%s requires a %s field extractor", key, base)
+		}
+	}
+
+	// Field value transforms (field-FIELDNAME-transform options)
+	var hmacKey []byte
+	if v, ok := opts["hmac-key-file"]; ok && v != "" {
+		hmacKey, err = readHMACKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hmac-key-file %q: %w", v, err)
+		}
+	}
+	for key, v := range opts {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(key, "field-"), "-transform")
+		if !ok || !strings.HasPrefix(key, "field-") {
+			continue
+		}
+		if !validFieldName(name) {
+			return nil, fmt.Errorf("invalid field transform key %q: %q is not a valid journal field name", key, name)
+		}
+		if untransformedFields[name] {
+			return nil, fmt.Errorf("invalid field transform key %q: %s cannot be transformed", key, name)
+		}
+		chain, err := parseFieldTransforms(v, hmacKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if cfg.FieldTransforms == nil {
+			cfg.FieldTransforms = make(map[string][]fieldTransform)
+		}
+		cfg.FieldTransforms[name] = chain
+	}
+
+	// Raw line preservation
+	switch v := opts["keep-raw"]; v {
+	case "", "false":
+	case "true":
+		cfg.KeepRaw = true
+	case "on-change":
+		cfg.KeepRaw = true
+		cfg.KeepRawOnChange = true
+	default:
+		return nil, fmt.Errorf("invalid keep-raw %q: must be true, false or on-change", v)
+	}
+	// RAW_MESSAGE would expose the values the transforms hash or cut
+	if cfg.KeepRaw && len(cfg.FieldTransforms) > 0 {
+		return nil, fmt.Errorf("keep-raw cannot be combined with field transforms")
+	}
+	if v, ok := opts["keep-raw-max-bytes"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2*len(truncatedMarker) {
+			return nil, fmt.Errorf("invalid keep-raw-max-bytes %q: must be an integer of at least %d", v, 2*len(truncatedMarker))
+		}
+		cfg.KeepRawMaxBytes = n
+	}
+
+	// Field budgets
+	if v, ok := opts["max-field-bytes"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2*len(truncatedMarker) {
+			return nil, fmt.Errorf("invalid max-field-bytes %q: must be an integer of at least %d", v, 2*len(truncatedMarker))
+		}
+		cfg.MaxFieldBytes = n
+	}
+	if v, ok := opts["max-fields"]; ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid max-fields %q: must be a positive integer", v)
+		}
+		cfg.MaxFields = n
+	}
+
+	// Named group field regexes (field-regex[-N] options), in key order
+	var regexKeys []string
+	for key := range opts {
+		if isFieldRegexKey(key) {
+			regexKeys = append(regexKeys, key)
+		}
+	}
+	sortOptionKeys(regexKeys)
+	for _, key := range regexKeys {
+		r, err := parseFieldRegex(opts[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, opts[key], err)
+		}
+		cfg.FieldRegexes = append(cfg.FieldRegexes, r)
+	}
+
+	// Grok patterns (grok-pattern[-N] options), in key order after field-regex
+	custom, err := parseGrokDefines(opts)
+	if err != nil {
+		return nil, err
+	}
+	var grokKeys []string
+	for key := range opts {
+		if isGrokPatternKey(key) {
+			grokKeys = append(grokKeys, key)
+		}
+	}
+	sortOptionKeys(grokKeys)
+	for _, key := range grokKeys {
+		expanded, err := expandGrok(opts[key], custom)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, opts[key], err)
+		}
+		r, err := parseFieldRegex(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, opts[key], err)
+		}
+		cfg.FieldRegexes = append(cfg.FieldRegexes, r)
+	}
+
+	// Message template and derived fields (derived-field-FIELDNAME options)
+	if v, ok := opts["message-template"]; ok && v != "" {
+		t, err := parseFieldTemplate("message-template", v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MessageTemplate = t
+	}
+	for key, v := range opts {
+		fieldName, ok := strings.CutPrefix(key, "derived-field-")
+		if !ok {
+			continue
+		}
+		if !validFieldName(fieldName) {
+			return nil, fmt.Errorf("invalid derived field key %q: %q is not a valid journal field name", key, fieldName)
+		}
+		if reservedFieldNames[fieldName] {
+			return nil, fmt.Errorf("invalid derived field key %q: %s is set by the driver", key, fieldName)
+		}
+		if v == "" {
+			return nil, fmt.Errorf("invalid derived field %q: template cannot be empty", key)
+		}
+		t, err := parseFieldTemplate(key, v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DerivedFields = append(cfg.DerivedFields, derivedField{fieldName, t})
+	}
+	sort.Slice(cfg.DerivedFields, func(i, j int) bool {
+		return cfg.DerivedFields[i].FieldName < cfg.DerivedFields[j].FieldName
+	})
 
 	return cfg, nil
 }
 
+// sortOptionKeys sorts numbered option keys (e.g. field-regex-N) by name,
+// comparing a trailing number as a number, so -2 comes before -10.
+func sortOptionKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, an := splitOptionNumber(keys[i])
+		b, bn := splitOptionNumber(keys[j])
+		if a == b && an >= 0 && bn >= 0 && an != bn {
+			return an < bn
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// splitOptionNumber splits a key into its name and trailing number, -1 if
+// it has none.
+func splitOptionNumber(key string) (string, int) {
+	i := len(key)
+	for i > 0 && key[i-1] >= '0' && key[i-1] <= '9' {
+		i--
+	}
+	n, err := strconv.Atoi(key[i:])
+	if err != nil {
+		return key, -1
+	}
+	return key[:i], n
+}
+
+func isFieldRegexKey(key string) bool {
+	return key == "field-regex" || strings.HasPrefix(key, "field-regex-")
+}
+
+// parseFieldRegex compiles a field-regex pattern. Every capture group must
+// be named after the journal field it extracts.
+func parseFieldRegex(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if r.NumSubexp() == 0 {
+		return nil, fmt.Errorf("must contain at least one named group (?P<FIELD>...)")
+	}
+	for i, name := range r.SubexpNames()[1:] {
+		if name == "" {
+			return nil, fmt.Errorf("capture group %d is not named, use (?P<FIELD>...) or (?:...)", i+1)
+		}
+		if !validFieldName(name) {
+			return nil, fmt.Errorf("group %q is not a valid journal field name", name)
+		}
+		if reservedFieldNames[name] {
+			return nil, fmt.Errorf("group %q is a field set by the driver", name)
+		}
+	}
+	return r, nil
+}
+
+// parseFieldTemplate compiles a message-template or derived-field template.
+// Missing keys are errors, so that entries without the referenced fields
+// keep their message and get no derived field.
+func parseFieldTemplate(opt, text string) (*template.Template, error) {
+	t, err := template.New(opt).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template %q: %w", opt, text, err)
+	}
+	return t, nil
+}
+
 func parsePriorityName(s string) (Priority, error) {
 	p, ok := priorityNames[strings.ToLower(s)]
 	if !ok {
@@ -360,18 +1024,91 @@ func parsePriorityName(s string) (Priority, error) {
 	return p, nil
 }
 
-// ExtractFields applies field extractors to a message and returns extracted field values.
-// Returns a map of field names to extracted values.
-func (c *Config) ExtractFields(message string) map[string]string {
-	if len(c.FieldExtractors) == 0 {
+// jsonFieldName returns the journal field name for a JSON key, or false if
+// the key is filtered out. Mapped keys are always kept; other keys must pass
+// the include and exclude globs and get the JSON field prefix.
+func (c *Config) jsonFieldName(key string) (string, bool) {
+	if name, ok := c.JSONFieldMap[key]; ok {
+		return name, true
+	}
+	if c.JSONFieldsInclude != nil && !matchAnyGlob(c.JSONFieldsInclude, key) {
+		return "", false
+	}
+	if matchAnyGlob(c.JSONFieldsExclude, key) {
+		return "", false
+	}
+	return c.JSONFieldPrefix + sanitizeFieldName(key), true
+}
+
+func matchAnyGlob(globs []string, s string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isFieldOptionKey reports whether an option key is a field-FIELDNAME-match,
+// -source or -transform option rather than an extractor.
+func isFieldOptionKey(key string) bool {
+	return strings.HasSuffix(key, "-match") || strings.HasSuffix(key, "-source") || strings.HasSuffix(key, "-transform")
+}
+
+// parseFieldSource parses a field-FIELDNAME-source value into the source
+// kind and key. An empty value selects the message.
+func parseFieldSource(key, value string) (string, string, error) {
+	switch value {
+	case "", "message":
+		return "message", "", nil
+	case "raw":
+		return "raw", "", nil
+	}
+	kind, name, ok := strings.Cut(value, ":")
+	if !ok || name == "" || (kind != "json" && kind != "label" && kind != "env") {
+		return "", "", fmt.Errorf("invalid %s-source %q: must be message, raw, json:<key>, label:<key> or env:<key>", key, value)
+	}
+	return kind, name, nil
+}
+
+// ExtractFields applies field regexes and extractors to a message and returns
+// extracted field values. Extractors reading other sources find no input.
+func (c *Config) ExtractFields(message string) Fields {
+	return c.extractFields(&fieldSource{Message: message})
+}
+
+// extractFields applies field regexes to the message and each extractor to
+// its source. Extractors win over regex groups of the same name. Extractors
+// with -match=all return a value for every match.
+func (c *Config) extractFields(src *fieldSource) Fields {
+	if len(c.FieldExtractors) == 0 && len(c.FieldRegexes) == 0 {
 		return nil
 	}
-	fields := make(map[string]string)
-	for _, extractor := range c.FieldExtractors {
-		matches := extractor.Regex.FindStringSubmatch(message)
-		if len(matches) > 1 {
-			// Use first capture group
-			fields[extractor.FieldName] = matches[1]
+	fields := make(Fields)
+	for _, r := range c.FieldRegexes {
+		matches := r.FindStringSubmatch(src.Message)
+		for i, name := range r.SubexpNames() {
+			if i > 0 && i < len(matches) && matches[i] != "" {
+				fields.Set(name, matches[i])
+			}
+		}
+	}
+	for i := range c.FieldExtractors {
+		extractor := &c.FieldExtractors[i]
+		var values []string
+		for _, input := range extractor.inputs(src) {
+			if extractor.All {
+				for _, m := range extractor.Regex.FindAllStringSubmatch(input, -1) {
+					values = append(values, m[1])
+				}
+			} else if matches := extractor.Regex.FindStringSubmatch(input); len(matches) > 1 {
+				// Use first capture group of the first matching input
+				values = append(values, matches[1])
+				break
+			}
+		}
+		if len(values) > 0 {
+			fields[extractor.FieldName] = values
 		}
 	}
 	if len(fields) == 0 {
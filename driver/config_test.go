@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -249,11 +250,100 @@ func TestParseConfigRejectsInvalid(t *testing.T) {
 		{"bad match regex", map[string]string{"priority-match-err": "[broken"}},
 		{"bad labels-regex", map[string]string{"labels-regex": "[broken"}},
 		{"bad env-regex", map[string]string{"env-regex": "[broken"}},
+		{"bad priority-explain", map[string]string{"priority-explain": "maybe"}},
+		{"bad strip-timestamp-regex", map[string]string{"strip-timestamp": "true", "strip-timestamp-regex": "[broken"}},
+		{"bad strip-timestamp-extra-regex", map[string]string{"strip-timestamp": "true", "strip-timestamp-extra-regex-1": "[broken"}},
+		{"unknown timestamp format", map[string]string{"strip-timestamp": "true", "strip-timestamp-formats": "iso8601,rfc2822"}},
+		{"timestamp formats with regex", map[string]string{"strip-timestamp": "true", "strip-timestamp-formats": "iso8601", "strip-timestamp-regex": "^x"}},
+		{"bad timestamp-source", map[string]string{"timestamp-source": "file"}},
+		{"app timestamp without strip", map[string]string{"timestamp-source": "app"}},
+		{"bad timestamp-timezone", map[string]string{"strip-timestamp": "true", "timestamp-timezone": "Mars/Olympus"}},
+		{"bad timestamp-max-skew", map[string]string{"timestamp-max-skew": "far"}},
+		{"level-map missing colon", map[string]string{"level-map": "SEVERE"}},
+		{"level-map bad priority", map[string]string{"level-map": "SEVERE:error"}},
+		{"bad level-scheme", map[string]string{"level-scheme": "log4j"}},
 		{"bad parse-json", map[string]string{"parse-json": "maybe"}},
+		{"bad parse-logfmt", map[string]string{"parse-logfmt": "maybe"}},
+		{"bad json-prefix-regex", map[string]string{"json-prefix-regex": "[unclosed"}},
+		{"json-prefix-field without regex", map[string]string{"json-prefix-field": "LOG_PREFIX"}},
+		{"bad json-prefix-field", map[string]string{"json-prefix-regex": ": ", "json-prefix-field": "_PREFIX"}},
+		{"json-prefix-field message", map[string]string{"json-prefix-regex": ": ", "json-prefix-field": "MESSAGE"}},
+		{"json-prefix-field syslog identifier", map[string]string{"json-prefix-regex": ": ", "json-prefix-field": "SYSLOG_IDENTIFIER"}},
+		{"bad json-unwrap-string", map[string]string{"json-unwrap-string": "maybe"}},
+		{"bad json-split-arrays", map[string]string{"parse-json": "true", "json-split-arrays": "maybe"}},
+		{"json-split-arrays without parse-json", map[string]string{"json-split-arrays": "true"}},
+		{"bad field-regex", map[string]string{"field-regex-1": `(?P<ID>[a-z`}},
+		{"empty field-regex", map[string]string{"field-regex": ""}},
+		{"field-regex without groups", map[string]string{"field-regex-1": `id=\w+`}},
+		{"field-regex unnamed group", map[string]string{"field-regex-1": `(?P<ID>\w+) (\d+)`}},
+		{"field-regex invalid name", map[string]string{"field-regex-1": `(?P<request_id>\w+)`}},
+		{"field-regex reserved name", map[string]string{"field-regex-1": `^(?P<PRIORITY>\d)`}},
+		{"field-regex message name", map[string]string{"field-regex-1": `(?P<MESSAGE>.*)`}},
+		{"grok-pattern reserved name", map[string]string{"grok-pattern-1": `%{WORD:SYSLOG_IDENTIFIER}`}},
+		{"field-regex underscore name", map[string]string{"field-regex-1": `(?P<_ID>\w+)`}},
+		{"grok-pattern unknown pattern", map[string]string{"grok-pattern": `%{NOPE:ID}`}},
+		{"grok-pattern without fields", map[string]string{"grok-pattern-1": `%{IP} %{WORD}`}},
+		{"grok-pattern invalid field", map[string]string{"grok-pattern-1": `%{IP:client}`}},
+		{"grok-pattern bad regex", map[string]string{"grok-pattern-1": `%{IP:CLIENT} [`}},
+		{"bad grok-define name", map[string]string{"grok-define-MY-ID": `\d+`}},
+		{"empty grok-define", map[string]string{"grok-define-ID": ""}},
+		{"unknown grok option", map[string]string{"grok-patterns": `%{IP:CLIENT}`}},
+		{"unknown field transform", map[string]string{"field-USER-transform": "lower,md5"}},
+		{"bad field transform truncate", map[string]string{"field-USER-transform": "truncate:0"}},
+		{"field transform hmac without key", map[string]string{"field-USER-transform": "hmac"}},
+		{"field transform on message", map[string]string{"field-MESSAGE-transform": "sha256"}},
+		{"field transform on repeat count", map[string]string{"field-REPEAT_COUNT-transform": "upper"}},
+		{"bad field transform name", map[string]string{"field-user-transform": "lower"}},
+		{"field transform with keep-raw", map[string]string{"field-USER-transform": "sha256", "keep-raw": "on-change"}},
+		{"missing hmac-key-file", map[string]string{"hmac-key-file": "/nonexistent/hmac.key"}},
+		{"bad parse-xml", map[string]string{"parse-xml": "logback"}},
+		{"bad parse-accesslog", map[string]string{"parse-accesslog": "apache"}},
+		{"accesslog-priority missing colon", map[string]string{"accesslog-priority": "5xx"}},
+		{"accesslog-priority bad status", map[string]string{"accesslog-priority": "6xx:err"}},
+		{"accesslog-priority bad class", map[string]string{"accesslog-priority": "5x:err"}},
+		{"accesslog-priority bad priority", map[string]string{"accesslog-priority": "5xx:error"}},
+		{"bad json-schema", map[string]string{"json-schema": "logstash"}},
+		{"bad keep-raw", map[string]string{"keep-raw": "always"}},
+		{"bad keep-raw-max-bytes", map[string]string{"keep-raw-max-bytes": "1"}},
+		{"bad max-field-bytes", map[string]string{"max-field-bytes": "abc"}},
+		{"max-field-bytes too small", map[string]string{"max-field-bytes": "10"}},
+		{"bad max-fields", map[string]string{"max-fields": "0"}},
+		{"bad message-template", map[string]string{"message-template": "{{.method"}},
+		{"bad derived-field template", map[string]string{"derived-field-ENDPOINT": "{{if}}"}},
+		{"bad derived-field name", map[string]string{"derived-field-endpoint": "{{.path}}"}},
+		{"derived-field priority", map[string]string{"derived-field-PRIORITY": "0"}},
+		{"derived-field message", map[string]string{"derived-field-MESSAGE": "{{.path}}"}},
+		{"derived-field syslog identifier", map[string]string{"derived-field-SYSLOG_IDENTIFIER": "sshd"}},
+		{"derived-field container name", map[string]string{"derived-field-CONTAINER_NAME": "x"}},
+		{"empty derived-field name", map[string]string{"derived-field-": "{{.path}}"}},
+		{"empty derived-field template", map[string]string{"derived-field-ENDPOINT": ""}},
 		{"field extractor no capture group", map[string]string{"field-REQUEST_ID": "request_id=[a-z0-9]+"}},
 		{"field extractor bad regex", map[string]string{"field-USER_ID": "[invalid"}},
 		{"field extractor empty name", map[string]string{"field-": "pattern"}},
 		{"field extractor empty pattern", map[string]string{"field-TEST": ""}},
+		{"bad field match mode", map[string]string{"field-TEST": `id=(\d+)`, "field-TEST-match": "some"}},
+		{"field match without extractor", map[string]string{"field-TEST-match": "all"}},
+		{"bad field source", map[string]string{"field-TEST": `(\d+)`, "field-TEST-source": "stderr"}},
+		{"field source missing key", map[string]string{"field-TEST": `(\d+)`, "field-TEST-source": "json:"}},
+		{"field source without extractor", map[string]string{"field-TEST-source": "raw"}},
+		{"field match on field-regex", map[string]string{"field-regex-1": `(?P<ID>\d+)`, "field-regex-1-match": "all"}},
+		{"bad json-flatten", map[string]string{"json-flatten": "maybe"}},
+		{"bad json-flatten-depth", map[string]string{"json-flatten-depth": "0"}},
+		{"json-field-map missing colon", map[string]string{"json-field-map": "trace_id"}},
+		{"json-field-map bad field name", map[string]string{"json-field-map": "trace_id:trace-id"}},
+		{"json-field-map reserved field name", map[string]string{"json-field-map": "x:MESSAGE"}},
+		{"json-field-map metadata field name", map[string]string{"json-field-map": "name:CONTAINER_NAME"}},
+		{"json-field-map underscore field name", map[string]string{"json-field-map": "trace_id:_TRACE"}},
+		{"bad json-fields-include glob", map[string]string{"json-fields-include": "[a-"}},
+		{"bad json-field-prefix", map[string]string{"json-field-prefix": "json."}},
+		{"bad dedupe-window", map[string]string{"dedupe-window": "often"}},
+		{"negative dedupe-window", map[string]string{"dedupe-window": "-1s"}},
+		{"bad dedupe-fuzzy", map[string]string{"dedupe-fuzzy": "maybe"}},
+		{"bad sample priority", map[string]string{"sample-verbose": "0.1"}},
+		{"bad sample rate", map[string]string{"sample-debug": "lots"}},
+		{"sample rate above one", map[string]string{"sample-debug": "1.5"}},
+		{"bad sample-mode", map[string]string{"sample-mode": "sometimes"}},
+		{"consistent sampling without key", map[string]string{"sample-mode": "consistent"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -265,6 +355,24 @@ func TestParseConfigRejectsInvalid(t *testing.T) {
 	}
 }
 
+func TestParseConfigSampleRates(t *testing.T) {
+	cfg, err := ParseConfig(map[string]string{
+		"sample-debug": "0.01",
+		"sample-info":  "0.5",
+		"sample-mode":  "consistent",
+		"sample-key":   "trace_id",
+	})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if len(cfg.SampleRates) != 2 || cfg.SampleRates[PriDebug] != 0.01 || cfg.SampleRates[PriInfo] != 0.5 {
+		t.Errorf("SampleRates = %v", cfg.SampleRates)
+	}
+	if cfg.SampleKey != "trace_id" {
+		t.Errorf("SampleKey = %q, want trace_id", cfg.SampleKey)
+	}
+}
+
 func TestParseConfigFieldExtractors(t *testing.T) {
 	cfg, err := ParseConfig(map[string]string{
 		"field-REQUEST_ID": `request_id=([a-z0-9]+)`,
@@ -353,8 +461,131 @@ func TestExtractFields(t *testing.T) {
 				t.Errorf("got %d fields, want %d: %v", len(got), len(tt.want), got)
 			}
 			for k, v := range tt.want {
-				if got[k] != v {
-					t.Errorf("field %s = %q, want %q", k, got[k], v)
+				if got.Get(k) != v {
+					t.Errorf("field %s = %q, want %q", k, got.Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractFieldsMatchAll(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"field-ITEM":         `item=(\w+)`,
+		"field-ITEM-match":   "all",
+		"field-STATUS":       `status=(\w+)`,
+		"field-STATUS-match": "first",
+	})
+
+	got := cfg.ExtractFields("item=a item=b status=ok item=c status=late")
+	if !reflect.DeepEqual(got["ITEM"], []string{"a", "b", "c"}) {
+		t.Errorf("ITEM = %q, want [a b c]", got["ITEM"])
+	}
+	if !reflect.DeepEqual(got["STATUS"], []string{"ok"}) {
+		t.Errorf("STATUS = %q, want [ok]", got["STATUS"])
+	}
+	if got := cfg.ExtractFields("nothing here"); len(got) != 0 {
+		t.Errorf("got %v, want no fields", got)
+	}
+}
+
+func TestExtractFieldsSources(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"field-MSG":           `id=(\w+)`,
+		"field-RAW":           `^(\S+)`,
+		"field-RAW-source":    "raw",
+		"field-DB":            `@([\w.]+)/`,
+		"field-DB-source":     "json:dsn",
+		"field-TAG":           `^(\w+)$`,
+		"field-TAG-source":    "json:tags",
+		"field-TAG-match":     "all",
+		"field-MAJOR":         `^v?(\d+)\.`,
+		"field-MAJOR-source":  "label:version",
+		"field-REGION":        `^(\w+)-`,
+		"field-REGION-source": "env:ZONE",
+	})
+
+	src := &fieldSource{
+		Message: "done id=m1",
+		Raw:     `2024-01-15T10:30:45Z {"msg":"done id=m1"}`,
+		JSON:    Fields{"dsn": {"postgres://app@db.internal/shop"}, "tags": {"web", "eu", "not a tag"}},
+		Labels:  map[string]string{"version": "v2.14.1"},
+		Env:     map[string]string{"ZONE": "eu-west-1a"},
+	}
+	want := Fields{
+		"MSG":    {"m1"},
+		"RAW":    {"2024-01-15T10:30:45Z"},
+		"DB":     {"db.internal"},
+		"TAG":    {"web", "eu"},
+		"MAJOR":  {"2"},
+		"REGION": {"eu"},
+	}
+	if got := cfg.extractFields(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Missing sources extract nothing
+	want = Fields{"MSG": {"m1"}}
+	if got := cfg.ExtractFields("done id=m1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSortOptionKeys(t *testing.T) {
+	keys := []string{"field-regex-10", "field-regex-2", "field-regex-b", "field-regex", "field-regex-1", "field-regex-a"}
+	sortOptionKeys(keys)
+	want := []string{"field-regex", "field-regex-1", "field-regex-2", "field-regex-10", "field-regex-a", "field-regex-b"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+}
+
+func TestExtractFieldsNamedGroups(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"field-regex-1": `^\[(?P<REQUEST_ID>[a-z0-9]+)\] (?P<USER>\w+)@(?P<TENANT>\w+)(?: (?P<ROLE>admin))?`,
+		"field-regex-2": `took (?P<DURATION_MS>\d+)ms`,
+		"field-USER":    `user=(\w+)`,
+	})
+	if len(cfg.FieldRegexes) != 2 || len(cfg.FieldExtractors) != 1 {
+		t.Fatalf("got %d field regexes and %d extractors, want 2 and 1", len(cfg.FieldRegexes), len(cfg.FieldExtractors))
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    map[string]string
+	}{
+		{
+			name:    "all groups",
+			message: "[ab12] bob@acme admin took 15ms",
+			want:    map[string]string{"REQUEST_ID": "ab12", "USER": "bob", "TENANT": "acme", "ROLE": "admin", "DURATION_MS": "15"},
+		},
+		{
+			name:    "optional group not matched",
+			message: "[ab12] bob@acme",
+			want:    map[string]string{"REQUEST_ID": "ab12", "USER": "bob", "TENANT": "acme"},
+		},
+		{
+			name:    "extractor wins over group",
+			message: "[ab12] bob@acme user=alice",
+			want:    map[string]string{"REQUEST_ID": "ab12", "USER": "alice", "TENANT": "acme"},
+		},
+		{
+			name:    "no match",
+			message: "plain line",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cfg.ExtractFields(tt.message)
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got.Get(k) != v {
+					t.Errorf("field %s = %q, want %q", k, got.Get(k), v)
 				}
 			}
 		})
@@ -372,3 +603,45 @@ func TestExtractFieldsNoExtractors(t *testing.T) {
 		t.Errorf("expected nil when no extractors configured, got %v", result)
 	}
 }
+
+func TestJSONFieldName(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"json-field-map":      "trace_id:TRACE_ID, user.id:USER_ID",
+		"json-fields-include": "http.*,user.*,trace_id,span_id,debug_*",
+		"json-fields-exclude": "debug_*,http.headers.*",
+		"json-field-prefix":   "APP_",
+	})
+
+	tests := []struct {
+		key      string
+		wantName string
+		wantOK   bool
+	}{
+		{"trace_id", "TRACE_ID", true},
+		{"user.id", "USER_ID", true},
+		{"user.name", "APP_USER_NAME", true},
+		{"http.status", "APP_HTTP_STATUS", true},
+		{"http.headers.host", "", false},
+		{"span_id", "APP_SPAN_ID", true},
+		{"debug_dump", "", false},
+		{"hostname", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := cfg.jsonFieldName(tt.key)
+		if ok != tt.wantOK || name != tt.wantName {
+			t.Errorf("jsonFieldName(%q) = (%q, %v), want (%q, %v)", tt.key, name, ok, tt.wantName, tt.wantOK)
+		}
+	}
+
+	// Defaults: everything kept with JSON_ prefix
+	cfg = mustConfig(t, map[string]string{})
+	if name, ok := cfg.jsonFieldName("request_id"); !ok || name != "JSON_REQUEST_ID" {
+		t.Errorf("default jsonFieldName = (%q, %v), want JSON_REQUEST_ID", name, ok)
+	}
+
+	// Empty prefix
+	cfg = mustConfig(t, map[string]string{"json-field-prefix": ""})
+	if name, ok := cfg.jsonFieldName("request_id"); !ok || name != "REQUEST_ID" {
+		t.Errorf("unprefixed jsonFieldName = (%q, %v), want REQUEST_ID", name, ok)
+	}
+}
@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// journalEntry is a fully processed message, ready to be written to journald.
+type journalEntry struct {
+	Msg        mergedMessage
+	Priority   Priority
+	Line       []byte
+	JSONFields Fields
+}
+
+// Patterns masked in fuzzy dedupe mode. Order matters: UUIDs and hex IDs
+// must be masked before plain digit runs break them apart.
+var (
+	dedupeUUID  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	dedupeHex   = regexp.MustCompile(`\b(0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`)
+	dedupeDigit = regexp.MustCompile(`\d+`)
+)
+
+// deduper collapses identical consecutive entries into the first entry plus
+// a "last message repeated N times" summary with a REPEAT_COUNT field.
+type deduper struct {
+	cfg    *Config
+	output func(journalEntry)
+
+	mu         sync.Mutex
+	key        string
+	last       journalEntry
+	count      int
+	active     bool
+	timer      *time.Timer
+	generation uint64
+}
+
+func newDeduper(cfg *Config, output func(journalEntry)) *deduper {
+	return &deduper{
+		cfg:    cfg,
+		output: output,
+	}
+}
+
+// Add processes a single entry, either writing it or counting it as a repeat.
+func (d *deduper) Add(e journalEntry) {
+	// If dedupe is disabled, pass through directly
+	if d.cfg.DedupeWindow <= 0 {
+		d.output(e)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := d.entryKey(e)
+	if d.active && key == d.key {
+		d.last = e
+		d.count++
+		return
+	}
+
+	// New message -- summarize any repeats of the previous one first
+	d.flushLocked()
+	d.output(e)
+	d.key = key
+	d.last = e
+	d.count = 0
+	d.active = true
+	d.startTimerLocked()
+}
+
+// Flush emits any pending repeat summary and ends the current window.
+func (d *deduper) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flushLocked()
+}
+
+func (d *deduper) flushLocked() {
+	if !d.active {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.count > 0 {
+		d.output(repeatSummary(d.last, d.count))
+	}
+	d.active = false
+	d.count = 0
+	d.key = ""
+	d.last = journalEntry{}
+}
+
+func (d *deduper) startTimerLocked() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.generation++
+	currentGen := d.generation
+
+	d.timer = time.AfterFunc(d.cfg.DedupeWindow, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.generation != currentGen {
+			return // Stale timer, abort
+		}
+
+		d.flushLocked()
+	})
+}
+
+// entryKey returns the comparison key for an entry. Entries are only equal
+// if they come from the same stream with the same priority.
+func (d *deduper) entryKey(e journalEntry) string {
+	line := e.Line
+	if d.cfg.DedupeFuzzy {
+		line = maskVariableParts(line)
+	}
+	return e.Msg.Source + "\x00" + strconv.Itoa(int(e.Priority)) + "\x00" + string(line)
+}
+
+// maskVariableParts replaces UUIDs, hex IDs and digit runs with fixed
+// placeholders, so that messages differing only in those compare equal.
+func maskVariableParts(line []byte) []byte {
+	line = dedupeUUID.ReplaceAllLiteral(line, []byte("<uuid>"))
+	line = dedupeHex.ReplaceAllLiteral(line, []byte("<hex>"))
+	return dedupeDigit.ReplaceAllLiteral(line, []byte("<n>"))
+}
+
+// repeatSummary builds the summary entry for n suppressed repeats of e.
+func repeatSummary(e journalEntry, n int) journalEntry {
+	fields := make(map[string]string, len(e.Msg.Fields)+1)
+	for k, v := range e.Msg.Fields {
+		fields[k] = v
+	}
+	fields["REPEAT_COUNT"] = strconv.Itoa(n)
+
+	msg := e.Msg
+	msg.Fields = fields
+	return journalEntry{
+		Msg:        msg,
+		Priority:   e.Priority,
+		Line:       []byte(fmt.Sprintf("last message repeated %d times", n)),
+		JSONFields: e.JSONFields,
+	}
+}
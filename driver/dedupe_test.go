@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type collectedEntries struct {
+	mu      sync.Mutex
+	entries []journalEntry
+}
+
+func (c *collectedEntries) add(e journalEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, e)
+}
+
+func (c *collectedEntries) get() []journalEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]journalEntry, len(c.entries))
+	copy(out, c.entries)
+	return out
+}
+
+func testEntry(line, source string, pri Priority, timeNano int64) journalEntry {
+	return journalEntry{
+		Msg:      mergedMessage{Line: []byte(line), Source: source, TimeNano: timeNano},
+		Priority: pri,
+		Line:     []byte(line),
+	}
+}
+
+func TestDedupeDisabled(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{})
+	var collected collectedEntries
+	d := newDeduper(cfg, collected.add)
+
+	for i := 0; i < 3; i++ {
+		d.Add(testEntry("connection refused", "stderr", PriErr, int64(i)))
+	}
+	d.Flush()
+
+	if got := len(collected.get()); got != 3 {
+		t.Fatalf("got %d entries, want 3", got)
+	}
+}
+
+func TestDedupeCollapsesRepeats(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"dedupe-window": "1s"})
+	var collected collectedEntries
+	d := newDeduper(cfg, collected.add)
+
+	for i := 0; i < 5; i++ {
+		d.Add(testEntry("connection refused", "stderr", PriErr, int64(1000+i)))
+	}
+	d.Add(testEntry("giving up", "stderr", PriErr, 2000))
+	d.Flush()
+
+	entries := collected.get()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if string(entries[0].Line) != "connection refused" {
+		t.Errorf("entry[0] = %q", string(entries[0].Line))
+	}
+	if entries[0].Msg.Fields["REPEAT_COUNT"] != "" {
+		t.Error("first entry should not carry REPEAT_COUNT")
+	}
+	if string(entries[1].Line) != "last message repeated 4 times" {
+		t.Errorf("entry[1] = %q", string(entries[1].Line))
+	}
+	if entries[1].Msg.Fields["REPEAT_COUNT"] != "4" {
+		t.Errorf("REPEAT_COUNT = %q, want 4", entries[1].Msg.Fields["REPEAT_COUNT"])
+	}
+	if entries[1].Priority != PriErr {
+		t.Errorf("summary priority = %d, want %d", entries[1].Priority, PriErr)
+	}
+	if entries[1].Msg.TimeNano != 1004 {
+		t.Errorf("summary TimeNano = %d, want 1004 (last repeat)", entries[1].Msg.TimeNano)
+	}
+	if string(entries[2].Line) != "giving up" {
+		t.Errorf("entry[2] = %q", string(entries[2].Line))
+	}
+}
+
+func TestDedupeKeyIncludesStreamAndPriority(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"dedupe-window": "1s"})
+	var collected collectedEntries
+	d := newDeduper(cfg, collected.add)
+
+	d.Add(testEntry("same text", "stdout", PriInfo, 1))
+	d.Add(testEntry("same text", "stderr", PriInfo, 2))
+	d.Add(testEntry("same text", "stderr", PriErr, 3))
+	d.Flush()
+
+	if got := len(collected.get()); got != 3 {
+		t.Fatalf("got %d entries, want 3 (different stream/priority must not collapse)", got)
+	}
+}
+
+func TestDedupeFuzzy(t *testing.T) {
+	tests := []struct {
+		name  string
+		fuzzy string
+		lines []string
+		want  int
+	}{
+		{"exact differs on digits", "false", []string{"retry 1 failed", "retry 2 failed", "retry 3 failed"}, 3},
+		{"fuzzy digits", "true", []string{"retry 1 failed", "retry 2 failed", "retry 3 failed"}, 2},
+		{"fuzzy hex", "true", []string{"conn 0x7f3a failed", "conn 0x91bc failed", "conn deadbeef01 failed"}, 2},
+		{"fuzzy uuid", "true", []string{
+			"req 550e8400-e29b-41d4-a716-446655440000 refused",
+			"req 6ba7b810-9dad-11d1-80b4-00c04fd430c8 refused",
+			"req 123e4567-e89b-12d3-a456-426614174000 refused",
+		}, 2},
+		{"fuzzy keeps words", "true", []string{"open failed", "close failed", "read failed"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mustConfig(t, map[string]string{
+				"dedupe-window": "1s",
+				"dedupe-fuzzy":  tt.fuzzy,
+			})
+			var collected collectedEntries
+			d := newDeduper(cfg, collected.add)
+			for i, line := range tt.lines {
+				d.Add(testEntry(line, "stdout", PriInfo, int64(i)))
+			}
+			d.Flush()
+
+			entries := collected.get()
+			if len(entries) != tt.want {
+				t.Fatalf("got %d entries, want %d: %v", len(entries), tt.want, entries)
+			}
+		})
+	}
+}
+
+func TestDedupeWindowExpiry(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"dedupe-window": "20ms"})
+	var collected collectedEntries
+	d := newDeduper(cfg, collected.add)
+
+	d.Add(testEntry("tick", "stdout", PriInfo, 1))
+	d.Add(testEntry("tick", "stdout", PriInfo, 2))
+
+	// Wait for the window to close and emit the summary
+	time.Sleep(50 * time.Millisecond)
+
+	entries := collected.get()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (window expiry should flush summary)", len(entries))
+	}
+	if entries[1].Msg.Fields["REPEAT_COUNT"] != "1" {
+		t.Errorf("REPEAT_COUNT = %q, want 1", entries[1].Msg.Fields["REPEAT_COUNT"])
+	}
+
+	// A repeat after the window is written as a fresh entry
+	d.Add(testEntry("tick", "stdout", PriInfo, 3))
+	d.Flush()
+	entries = collected.get()
+	if len(entries) != 3 || string(entries[2].Line) != "tick" {
+		t.Fatalf("expected fresh entry after window, got %v", entries)
+	}
+}
@@ -188,7 +188,8 @@ func (lc *logConsumer) logError(format string, args ...interface{}) {
 }
 
 // consumeLog reads log entries from the FIFO, reassembles partials,
-// merges multiline, detects priority, and writes to journald.
+// merges multiline, detects priority, samples and collapses repeats, and
+// writes to journald.
 func (d *Driver) consumeLog(ctx context.Context, f io.ReadCloser, lc *logConsumer) {
 	defer close(lc.done)
 	defer f.Close()
@@ -200,46 +201,36 @@ func (d *Driver) consumeLog(ctx context.Context, f io.ReadCloser, lc *logConsume
 	}()
 
 	partial := newPartialAssembler()
+	process := newMessageProcessor(lc.cfg, lc.writer.tagData)
+	sample := newSampler(lc.cfg)
 
-	merger := newMultilineMerger(lc.cfg, func(msg mergedMessage) {
-		line := msg.Line
-		var jsonFields map[string]string
-		var priority Priority
-		priorityDetected := false
-
-		// Try JSON parsing first if enabled
-		if parsed, ok := ParseJSONLog(lc.cfg, line); ok {
-			// JSON parsing succeeded
-			jsonFields = parsed.ExtraFields
-
-			// Use JSON message as log body
-			if parsed.Message != "" {
-				line = []byte(parsed.Message)
-			}
-
-			// Detect priority from JSON level field
-			if parsed.Level != "" {
-				if pri, ok := JSONLevelToPriority(parsed.Level); ok {
-					priority = pri
-					priorityDetected = true
-				}
-			}
+	dedupe := newDeduper(lc.cfg, func(e journalEntry) {
+		if err := lc.writer.Write(e.Msg, e.Priority, e.Line, e.JSONFields); err != nil {
+			lc.logError("error writing to journal: %v", err)
 		}
+	})
 
-		// Strip timestamp (before priority detection so ^ERROR matches after stripping)
-		if lc.cfg.StripTimestamp {
-			line = StripTimestamp(line, lc.cfg.StripTimestampPatterns)
-		}
+	handle := func(msg mergedMessage) {
+		entry := process.Process(msg)
 
-		// Detect priority via regex/default if not already detected from JSON
-		if !priorityDetected {
-			priority, line = DetectPriority(lc.cfg, line, msg.Source)
+		// Drop sampled-out low-severity entries
+		if !sample.Keep(&entry) {
+			return
 		}
 
-		// Write to journal with JSON fields
-		if err := lc.writer.Write(msg, priority, line, jsonFields); err != nil {
-			lc.logError("error writing to journal: %v", err)
+		// Collapse repeats, then write to journal with JSON fields
+		dedupe.Add(entry)
+	}
+
+	merger := newMultilineMerger(lc.cfg, func(msg mergedMessage) {
+		// Write each event of a JSON array batch separately
+		if batch, ok := SplitJSONArray(lc.cfg, msg); ok {
+			for _, m := range batch {
+				handle(m)
+			}
+			return
 		}
+		handle(msg)
 	})
 
 	dec := newLogEntryDecoder(f)
@@ -265,6 +256,7 @@ func (d *Driver) consumeLog(ctx context.Context, f io.ReadCloser, lc *logConsume
 
 	// Flush remaining buffered content
 	merger.Flush()
+	dedupe.Flush()
 }
 
 // --- HTTP helpers ---
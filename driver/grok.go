@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grokPatterns is the built-in grok pattern library, after the Logstash
+// grok-patterns file, rewritten for RE2 (no lookarounds or atomic groups).
+var grokPatterns = map[string]string{
+	// Text and numbers
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"SPACE":        `\s*`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":           `%{QUOTEDSTRING}`,
+	"INT":          `[+-]?[0-9]+`,
+	"BASE10NUM":    `[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)`,
+	"NUMBER":       `%{BASE10NUM}`,
+	"BASE16NUM":    `[+-]?(?:0x)?[0-9A-Fa-f]+`,
+	"POSINT":       `\b[1-9][0-9]*\b`,
+	"NONNEGINT":    `\b[0-9]+\b`,
+	"UUID":         `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"DURATION":     `(?:[0-9]+(?:\.[0-9]+)?(?:ns|us|µs|ms|s|m|h))+`,
+	"LOGLEVEL":     `[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo(?:rmation)?|INFO(?:RMATION)?|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Aa]lert|ALERT|[Ee]merg(?:ency)?|EMERG(?:ENCY)?`,
+
+	// Users and hosts
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"EMAILADDRESS": `[a-zA-Z0-9._%+-]+@%{HOSTNAME}`,
+	"IPV4":         `\b(?:(?:25[0-5]|2[0-4][0-9]|1?[0-9]{1,2})\.){3}(?:25[0-5]|2[0-4][0-9]|1?[0-9]{1,2})\b`,
+	"IPV6":         `(?:[A-Fa-f0-9]{1,4}:){7}[A-Fa-f0-9]{1,4}|(?:[A-Fa-f0-9]{1,4}:){1,6}:(?:[A-Fa-f0-9]{1,4}(?::[A-Fa-f0-9]{1,4})*)?|::(?:[A-Fa-f0-9]{1,4}(?::[A-Fa-f0-9]{1,4})*)?`,
+	"IP":           `%{IPV6}|%{IPV4}`,
+	"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":     `%{IP}|%{HOSTNAME}`,
+	"HOSTPORT":     `%{IPORHOST}:%{POSINT}`,
+	"MAC":          `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}`,
+
+	// Paths and URIs
+	"UNIXPATH":     `(?:/[\w%!$@:.,+~-]*)+`,
+	"WINPATH":      `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"PATH":         `%{UNIXPATH}|%{WINPATH}`,
+	"URIPROTO":     `[A-Za-z][A-Za-z0-9+.-]+`,
+	"URIHOST":      `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+`,
+	"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*`,
+	"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":          `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+
+	// Dates and times
+	"MONTH":             `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|June?|July?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHNUM":          `0?[1-9]|1[0-2]`,
+	"MONTHDAY":          `0[1-9]|[12][0-9]|3[01]|[1-9]`,
+	"DAY":               `\b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b`,
+	"YEAR":              `[0-9]{2}(?:[0-9]{2})?`,
+	"HOUR":              `2[0-3]|[01]?[0-9]`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `Z|[+-](?:%{HOUR})(?::?%{MINUTE})`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+}
+
+// grokReference matches %{PATTERN}, %{PATTERN:FIELD} and %{PATTERN:FIELD:type}.
+// The Logstash type suffix is accepted, but values are always strings.
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([^:}]*))?(?::(?:int|float))?\}`)
+
+// grokPatternName matches the names of grok-define-NAME patterns.
+var grokPatternName = regexp.MustCompile(`^\w+$`)
+
+// grokMaxDepth limits nested pattern references, to catch cycles.
+const grokMaxDepth = 16
+
+func isGrokPatternKey(key string) bool {
+	return key == "grok-pattern" || strings.HasPrefix(key, "grok-pattern-")
+}
+
+// expandGrok replaces the pattern references in a grok pattern with their
+// regexes. Named references become named groups, others non-capturing
+// groups. Custom patterns win over built-in ones.
+func expandGrok(pattern string, custom map[string]string) (string, error) {
+	return expandGrokDepth(pattern, custom, 0)
+}
+
+func expandGrokDepth(pattern string, custom map[string]string, depth int) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range grokReference.FindAllStringSubmatchIndex(pattern, -1) {
+		name := pattern[m[2]:m[3]]
+		def, ok := custom[name]
+		if !ok {
+			def, ok = grokPatterns[name]
+		}
+		if !ok {
+			return "", fmt.Errorf("unknown grok pattern %q", name)
+		}
+		if depth >= grokMaxDepth {
+			return "", fmt.Errorf("grok pattern %q is nested too deeply (recursive definition?)", name)
+		}
+		expanded, err := expandGrokDepth(def, custom, depth+1)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(pattern[last:m[0]])
+		if m[4] >= 0 && m[5] > m[4] {
+			b.WriteString("(?P<" + pattern[m[4]:m[5]] + ">" + expanded + ")")
+		} else {
+			b.WriteString("(?:" + expanded + ")")
+		}
+		last = m[1]
+	}
+	b.WriteString(pattern[last:])
+	return b.String(), nil
+}
+
+// parseGrokDefines returns the custom patterns of the grok-define-NAME options.
+func parseGrokDefines(opts map[string]string) (map[string]string, error) {
+	custom := make(map[string]string)
+	for key, v := range opts {
+		name, ok := strings.CutPrefix(key, "grok-define-")
+		if !ok {
+			continue
+		}
+		if !grokPatternName.MatchString(name) {
+			return nil, fmt.Errorf("invalid grok pattern key %q: name must be letters, digits and underscores", key)
+		}
+		if v == "" {
+			return nil, fmt.Errorf("invalid %s: pattern cannot be empty", key)
+		}
+		custom[name] = v
+	}
+	return custom, nil
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGrokPatternsCompile(t *testing.T) {
+	for name := range grokPatterns {
+		expanded, err := expandGrok("%{"+name+"}", nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if _, err := regexp.Compile(expanded); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestGrokPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    string
+	}{
+		{"IP", "client 10.1.2.3 connected", "10.1.2.3"},
+		{"IP", "client fe80::1ff:fe23:4567:890a connected", "fe80::1ff:fe23:4567:890a"},
+		{"HOSTPORT", "upstream db.internal:5432 down", "db.internal:5432"},
+		{"UUID", "id=550e8400-e29b-41d4-a716-446655440000", "550e8400-e29b-41d4-a716-446655440000"},
+		{"DURATION", "took 1m30.5s total", "1m30.5s"},
+		{"DURATION", "took 250ms", "250ms"},
+		{"QUOTEDSTRING", `say "a \"b\" c" now`, `"a \"b\" c"`},
+		{"URIPATHPARAM", "GET /api/v1/items?id=5&x=y HTTP/1.1", "/api/v1/items?id=5&x=y"},
+		{"LOGLEVEL", "[WARNING] disk", "WARNING"},
+		{"TIMESTAMP_ISO8601", "at 2024-01-15T10:30:45.123Z ok", "2024-01-15T10:30:45.123Z"},
+		{"HTTPDATE", "[15/Jan/2024:10:30:45 +0000]", "15/Jan/2024:10:30:45 +0000"},
+		{"NUMBER", "load -1.5e", "-1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			expanded, err := expandGrok("%{"+tt.pattern+"}", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := regexp.MustCompile(expanded).FindString(tt.input); got != tt.want {
+				t.Errorf("match = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractFieldsGrok(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"grok-pattern-1":     `^%{IP:CLIENT} %{WORD:METHOD} %{URIPATH:PATH} %{POSINT:STATUS:int} in %{DURATION:ELAPSED}`,
+		"grok-pattern-2":     `tenant=%{TENANT:TENANT}`,
+		"grok-define-TENANT": `[a-z]+-%{POSINT}`,
+	})
+	if len(cfg.FieldRegexes) != 2 {
+		t.Fatalf("got %d field regexes, want 2", len(cfg.FieldRegexes))
+	}
+
+	got := cfg.ExtractFields("10.0.0.7 POST /orders 201 in 35ms tenant=acme-42")
+	want := Fields{
+		"CLIENT":  {"10.0.0.7"},
+		"METHOD":  {"POST"},
+		"PATH":    {"/orders"},
+		"STATUS":  {"201"},
+		"ELAPSED": {"35ms"},
+		"TENANT":  {"acme-42"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpandGrokErrors(t *testing.T) {
+	custom := map[string]string{"LOOP": "%{LOOP}"}
+	for _, pattern := range []string{"%{NOPE:X}", "%{LOOP}"} {
+		if _, err := expandGrok(pattern, custom); err == nil {
+			t.Errorf("expandGrok(%q): expected error", pattern)
+		}
+	}
+}
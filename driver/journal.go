@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -69,15 +70,40 @@ const defaultTagTemplate = "{{.Name}}"
 
 // journalWriter handles writing processed messages to journald.
 type journalWriter struct {
-	cfg      *Config
-	info     containerInfo
-	baseVars map[string]string // pre-computed journal fields
-	sendFn   func(message string, priority Priority, vars map[string]string) error
+	cfg         *Config
+	info        containerInfo
+	tagData     tagData           // container metadata for templates
+	baseVars    Fields            // pre-computed journal fields (shared, not modified)
+	mappedNames map[string]bool   // json-field-map field names, for max-fields
+	env         map[string]string // container environment, for field sources
+	sendFn      JournalSendFunc
 }
 
 // JournalSendFunc is the function signature for writing to journald.
-// In production this is journal.Send; in tests it can be replaced.
-type JournalSendFunc func(message string, priority Priority, vars map[string]string) error
+// In production this is defaultJournalSend; in tests it can be replaced.
+type JournalSendFunc func(message string, priority Priority, vars Fields) error
+
+// Fields holds journal field values. A field may have several values, which
+// journald stores as repeated fields with the same name.
+type Fields map[string][]string
+
+// Get returns the first value of a field, or "" if it has none.
+func (f Fields) Get(name string) string {
+	if v := f[name]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set replaces the values of a field with a single value.
+func (f Fields) Set(name, value string) {
+	f[name] = []string{value}
+}
+
+// Add appends a value to a field.
+func (f Fields) Add(name, value string) {
+	f[name] = append(f[name], value)
+}
 
 func newJournalWriter(cfg *Config, infoJSON json.RawMessage, sendFn JournalSendFunc) (*journalWriter, error) {
 	var info containerInfo
@@ -95,39 +121,44 @@ func newJournalWriter(cfg *Config, infoJSON json.RawMessage, sendFn JournalSendF
 		return nil, err
 	}
 	w.baseVars = baseVars
+	w.mappedNames = make(map[string]bool, len(cfg.JSONFieldMap))
+	for _, name := range cfg.JSONFieldMap {
+		w.mappedNames[name] = true
+	}
 	return w, nil
 }
 
-func (w *journalWriter) buildBaseVars() (map[string]string, error) {
-	vars := map[string]string{}
+func (w *journalWriter) buildBaseVars() (Fields, error) {
+	vars := Fields{}
 
 	td := newTagData(&w.info)
+	w.tagData = td
 
 	// Container metadata
-	vars["CONTAINER_ID"] = td.ID
-	vars["CONTAINER_ID_FULL"] = td.FullID
-	vars["CONTAINER_NAME"] = td.Name
-	vars["IMAGE_NAME"] = td.ImageName
+	vars.Set("CONTAINER_ID", td.ID)
+	vars.Set("CONTAINER_ID_FULL", td.FullID)
+	vars.Set("CONTAINER_NAME", td.Name)
+	vars.Set("IMAGE_NAME", td.ImageName)
 
 	// Tag: render Go template, default to {{.Name}}
 	tag, err := renderTag(w.cfg.Tag, td)
 	if err != nil {
 		return nil, fmt.Errorf("rendering tag template: %w", err)
 	}
-	vars["CONTAINER_TAG"] = tag
-	vars["SYSLOG_IDENTIFIER"] = tag
+	vars.Set("CONTAINER_TAG", tag)
+	vars.Set("SYSLOG_IDENTIFIER", tag)
 
 	// Include selected labels
 	w.addFilteredFields(vars, w.info.ContainerLabels, w.cfg.Labels, w.cfg.LabelsRegex)
 
 	// Include selected env vars
-	envMap := make(map[string]string)
+	w.env = make(map[string]string)
 	for _, e := range w.info.ContainerEnv {
 		if k, v, ok := strings.Cut(e, "="); ok {
-			envMap[k] = v
+			w.env[k] = v
 		}
 	}
-	w.addFilteredFields(vars, envMap, w.cfg.Env, w.cfg.EnvRegex)
+	w.addFilteredFields(vars, w.env, w.cfg.Env, w.cfg.EnvRegex)
 
 	return vars, nil
 }
@@ -157,7 +188,7 @@ func renderTag(tagTmpl string, td tagData) (string, error) {
 	return buf.String(), nil
 }
 
-func (w *journalWriter) addFilteredFields(vars map[string]string, source map[string]string, keys []string, re *regexp.Regexp) {
+func (w *journalWriter) addFilteredFields(vars Fields, source map[string]string, keys []string, re *regexp.Regexp) {
 	if len(keys) == 0 && re == nil {
 		return
 	}
@@ -170,7 +201,7 @@ func (w *journalWriter) addFilteredFields(vars map[string]string, source map[str
 	for k, v := range source {
 		if keySet[k] || (re != nil && re.MatchString(k)) {
 			fieldName := sanitizeFieldName(k)
-			vars[fieldName] = v
+			vars.Set(fieldName, v)
 		}
 	}
 }
@@ -201,22 +232,65 @@ func sanitizeFieldName(name string) string {
 	return result
 }
 
-// Write sends a log entry to journald with optional JSON-extracted fields.
-func (w *journalWriter) Write(msg mergedMessage, pri Priority, processedLine []byte, jsonFields map[string]string) error {
-	// Extract custom fields from the processed message
-	extractedFields := w.cfg.ExtractFields(string(processedLine))
+// reservedFieldNames are journal fields set by the driver itself, which log
+// content must not replace: the entry fields and the container metadata.
+var reservedFieldNames = map[string]bool{
+	"MESSAGE":                   true,
+	"PRIORITY":                  true,
+	"SYSLOG_IDENTIFIER":         true,
+	"SYSLOG_TIMESTAMP":          true,
+	"SOURCE_REALTIME_TIMESTAMP": true,
+	"CONTAINER_ID":              true,
+	"CONTAINER_ID_FULL":         true,
+	"CONTAINER_NAME":            true,
+	"CONTAINER_TAG":             true,
+	"IMAGE_NAME":                true,
+}
 
-	vars := make(map[string]string, len(w.baseVars)+2+len(jsonFields)+len(extractedFields))
+// validFieldName reports whether name is a legal journal field name for a
+// client: uppercase ASCII letters, digits and underscores, not starting with
+// a digit or underscore, and at most 64 characters.
+func validFieldName(name string) bool {
+	if name == "" || len(name) > 64 || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Write sends a log entry to journald with optional JSON-extracted fields.
+func (w *journalWriter) Write(msg mergedMessage, pri Priority, processedLine []byte, jsonFields Fields) error {
+	// Extract custom fields from the processed message or another source
+	extractedFields := w.cfg.extractFields(&fieldSource{
+		Message: string(processedLine),
+		Raw:     string(msg.raw()),
+		JSON:    jsonFields,
+		Labels:  w.info.ContainerLabels,
+		Env:     w.env,
+	})
+
+	vars := make(Fields, len(w.baseVars)+2+len(jsonFields)+len(extractedFields)+len(msg.Fields))
 
 	// Add base fields
 	for k, v := range w.baseVars {
 		vars[k] = v
 	}
 
-	// Add JSON fields with JSON_ prefix
+	// Add JSON fields (mapped, or filtered with JSON_ prefix)
 	if len(jsonFields) > 0 {
 		for k, v := range jsonFields {
-			fieldName := "JSON_" + sanitizeFieldName(k)
+			fieldName, ok := w.cfg.jsonFieldName(k)
+			if !ok || reservedFieldNames[fieldName] {
+				continue
+			}
+			if _, ok := w.baseVars[fieldName]; ok {
+				continue // Labels and env vars
+			}
 			vars[fieldName] = v
 		}
 	}
@@ -228,12 +302,27 @@ func (w *journalWriter) Write(msg mergedMessage, pri Priority, processedLine []b
 		}
 	}
 
-	// Add timestamp
+	// Add pipeline fields (dedupe counts etc.)
+	for k, v := range msg.Fields {
+		vars.Set(k, v)
+	}
+
+	// Apply value transforms (hashing etc.)
+	w.cfg.transformFields(vars)
+
+	// Add timestamp (application time if known, else Docker receive time)
 	ts := time.Unix(0, msg.TimeNano)
+	if msg.SourceTime != 0 {
+		ts = time.Unix(0, msg.SourceTime)
+		vars.Set("SOURCE_REALTIME_TIMESTAMP", strconv.FormatInt(ts.UnixMicro(), 10))
+	}
 	if !ts.IsZero() {
-		vars["SYSLOG_TIMESTAMP"] = ts.Format(time.RFC3339Nano)
+		vars.Set("SYSLOG_TIMESTAMP", ts.Format(time.RFC3339Nano))
 	}
 
+	// Enforce field count and size budgets
+	w.applyFieldBudgets(vars, &msg, extractedFields)
+
 	// Send to journal
 	return w.sendFn(string(processedLine), pri, vars)
 }
@@ -1,15 +1,125 @@
 package driver
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/coreos/go-systemd/v22/journal"
 )
 
-// defaultJournalSend writes a message to systemd journald via the native socket.
-func defaultJournalSend(message string, priority Priority, vars map[string]string) error {
-	return journal.Send(message, journal.Priority(priority), vars)
+// journalSocket is the journald native protocol socket (a variable for tests).
+var journalSocket = "/run/systemd/journal/socket"
+
+var (
+	journalConn     *net.UnixConn
+	journalConnErr  error
+	journalConnOnce sync.Once
+)
+
+// defaultJournalSend writes a message to systemd journald via the native
+// socket. Unlike journal.Send it supports repeated fields. Fields with
+// names journald would drop are skipped, and reported in the returned error
+// once the entry is sent. See https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+func defaultJournalSend(message string, priority Priority, vars Fields) error {
+	journalConnOnce.Do(func() {
+		journalConn, journalConnErr = net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	})
+	if journalConnErr != nil {
+		return fmt.Errorf("could not initialize socket to journald: %w", journalConnErr)
+	}
+
+	data, invalid := encodeJournalEntry(message, priority, vars)
+	if err := writeJournalEntry(data); err != nil {
+		return err
+	}
+	return invalid
+}
+
+// encodeJournalEntry encodes an entry in the native protocol format, with
+// the fields in name order. Fields with names journald would drop are
+// skipped and reported in the returned error.
+func encodeJournalEntry(message string, priority Priority, vars Fields) ([]byte, error) {
+	names := make([]string, 0, len(vars))
+	var invalid []string
+	for name := range vars {
+		if validFieldName(name) {
+			names = append(names, name)
+		} else {
+			invalid = append(invalid, name)
+		}
+	}
+	sort.Strings(names)
+
+	var data bytes.Buffer
+	appendJournalField(&data, "PRIORITY", strconv.Itoa(int(priority)))
+	appendJournalField(&data, "MESSAGE", message)
+	for _, name := range names {
+		for _, v := range vars[name] {
+			appendJournalField(&data, name, v)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return data.Bytes(), fmt.Errorf("skipped invalid journal field names %q", invalid)
+	}
+	return data.Bytes(), nil
+}
+
+// writeJournalEntry sends an encoded entry to the journald socket.
+func writeJournalEntry(data []byte) error {
+	addr := &net.UnixAddr{Name: journalSocket, Net: "unixgram"}
+	_, _, err := journalConn.WriteMsgUnix(data, nil, addr)
+	if err == nil || !isSocketSpaceError(err) {
+		return err
+	}
+
+	// Large entry, pass it as an unlinked temp file descriptor
+	f, err := os.CreateTemp("/dev/shm", "journal.")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	_, _, err = journalConn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+	return err
+}
+
+// appendJournalField encodes a field in the native protocol format. Values
+// containing newlines use the binary length-prefixed form.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// isSocketSpaceError reports whether a send failed because the datagram was
+// too large.
+func isSocketSpaceError(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
 }
 
 func init() {
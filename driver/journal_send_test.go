@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestEncodeJournalEntry(t *testing.T) {
+	vars := Fields{
+		"TAGS":     {"web", "eu"},
+		"STACK":    {"line1\nline2"},
+		"bad-name": {"x"},
+		"_TRUSTED": {"y"},
+	}
+	data, err := encodeJournalEntry("hello", PriWarning, vars)
+
+	want := "PRIORITY=4\nMESSAGE=hello\n" +
+		"STACK\n\x0b\x00\x00\x00\x00\x00\x00\x00line1\nline2\n" +
+		"TAGS=web\nTAGS=eu\n"
+	if string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	if err == nil || !strings.Contains(err.Error(), "bad-name") || !strings.Contains(err.Error(), "_TRUSTED") {
+		t.Errorf("err = %v, want invalid field names reported", err)
+	}
+
+	if _, err := encodeJournalEntry("hello", PriInfo, Fields{"OK": {"1"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIsSocketSpaceError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendmsg", syscall.EMSGSIZE)}, true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendmsg", syscall.ENOBUFS)}, true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendmsg", syscall.ECONNREFUSED)}, false},
+		{errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		if got := isSocketSpaceError(tt.err); got != tt.want {
+			t.Errorf("isSocketSpaceError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultJournalSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram socket not available: %v", err)
+	}
+	defer server.Close()
+	saved := journalSocket
+	journalSocket = path
+	defer func() { journalSocket = saved }()
+
+	// Small entries are sent as a datagram
+	if err := defaultJournalSend("hello", PriInfo, Fields{"TAGS": {"a", "b"}}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	buf := make([]byte, 1<<16)
+	n, _, _, _, err := server.ReadMsgUnix(buf, nil)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "PRIORITY=6\nMESSAGE=hello\nTAGS=a\nTAGS=b\n"; string(buf[:n]) != want {
+		t.Errorf("got %q, want %q", buf[:n], want)
+	}
+
+	// Entries too large for a datagram are passed as a file descriptor
+	large := strings.Repeat("x", 4<<20)
+	if err := defaultJournalSend(large, PriInfo, nil); err != nil {
+		t.Fatalf("send large: %v", err)
+	}
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := server.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("read large: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d bytes of datagram data, want none", n)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("control messages = %v, %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("unix rights = %v, %v", fds, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	f.Seek(0, io.SeekStart)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read fd: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PRIORITY=6\nMESSAGE=xxx")) || len(data) != len("PRIORITY=6\nMESSAGE=\n")+len(large) {
+		t.Errorf("fd entry has %d bytes, want the full entry", len(data))
+	}
+}
@@ -1,8 +1,11 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestJournalWriterBaseVars(t *testing.T) {
@@ -23,9 +26,9 @@ func TestJournalWriterBaseVars(t *testing.T) {
 
 	var lastMsg string
 	var lastPri Priority
-	var lastVars map[string]string
+	var lastVars Fields
 
-	sendFn := func(message string, priority Priority, vars map[string]string) error {
+	sendFn := func(message string, priority Priority, vars Fields) error {
 		lastMsg = message
 		lastPri = priority
 		lastVars = vars
@@ -48,23 +51,23 @@ func TestJournalWriterBaseVars(t *testing.T) {
 	if lastPri != PriInfo {
 		t.Errorf("priority = %d", lastPri)
 	}
-	if lastVars["CONTAINER_ID"] != "abcdef123456" {
-		t.Errorf("CONTAINER_ID = %q", lastVars["CONTAINER_ID"])
+	if lastVars.Get("CONTAINER_ID") != "abcdef123456" {
+		t.Errorf("CONTAINER_ID = %q", lastVars.Get("CONTAINER_ID"))
 	}
-	if lastVars["CONTAINER_NAME"] != "mycontainer" {
-		t.Errorf("CONTAINER_NAME = %q", lastVars["CONTAINER_NAME"])
+	if lastVars.Get("CONTAINER_NAME") != "mycontainer" {
+		t.Errorf("CONTAINER_NAME = %q", lastVars.Get("CONTAINER_NAME"))
 	}
-	if lastVars["SYSLOG_IDENTIFIER"] != "mycontainer" {
-		t.Errorf("SYSLOG_IDENTIFIER = %q (should default to container name)", lastVars["SYSLOG_IDENTIFIER"])
+	if lastVars.Get("SYSLOG_IDENTIFIER") != "mycontainer" {
+		t.Errorf("SYSLOG_IDENTIFIER = %q (should default to container name)", lastVars.Get("SYSLOG_IDENTIFIER"))
 	}
-	if lastVars["IMAGE_NAME"] != "myimage:latest" {
-		t.Errorf("IMAGE_NAME = %q", lastVars["IMAGE_NAME"])
+	if lastVars.Get("IMAGE_NAME") != "myimage:latest" {
+		t.Errorf("IMAGE_NAME = %q", lastVars.Get("IMAGE_NAME"))
 	}
-	if lastVars["APP"] != "web" {
-		t.Errorf("APP label = %q", lastVars["APP"])
+	if lastVars.Get("APP") != "web" {
+		t.Errorf("APP label = %q", lastVars.Get("APP"))
 	}
-	if lastVars["VERSION"] != "1.0" {
-		t.Errorf("VERSION label = %q", lastVars["VERSION"])
+	if lastVars.Get("VERSION") != "1.0" {
+		t.Errorf("VERSION label = %q", lastVars.Get("VERSION"))
 	}
 	if _, ok := lastVars["OTHER"]; ok {
 		t.Error("OTHER label should not be included")
@@ -81,8 +84,8 @@ func TestJournalWriterCustomTag(t *testing.T) {
 		ContainerName: "/container1",
 	})
 
-	var lastVars map[string]string
-	sendFn := func(message string, priority Priority, vars map[string]string) error {
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
 		lastVars = vars
 		return nil
 	}
@@ -95,11 +98,11 @@ func TestJournalWriterCustomTag(t *testing.T) {
 	msg := mergedMessage{Line: []byte("x"), Source: "stdout", TimeNano: 1000}
 	w.Write(msg, PriInfo, []byte("x"), nil)
 
-	if lastVars["SYSLOG_IDENTIFIER"] != "myapp" {
-		t.Errorf("SYSLOG_IDENTIFIER = %q, want %q", lastVars["SYSLOG_IDENTIFIER"], "myapp")
+	if lastVars.Get("SYSLOG_IDENTIFIER") != "myapp" {
+		t.Errorf("SYSLOG_IDENTIFIER = %q, want %q", lastVars.Get("SYSLOG_IDENTIFIER"), "myapp")
 	}
-	if lastVars["CONTAINER_TAG"] != "myapp" {
-		t.Errorf("CONTAINER_TAG = %q, want %q", lastVars["CONTAINER_TAG"], "myapp")
+	if lastVars.Get("CONTAINER_TAG") != "myapp" {
+		t.Errorf("CONTAINER_TAG = %q, want %q", lastVars.Get("CONTAINER_TAG"), "myapp")
 	}
 }
 
@@ -134,8 +137,8 @@ func TestJournalWriterTagTemplate(t *testing.T) {
 				DaemonName:         "docker",
 			})
 
-			var lastVars map[string]string
-			sendFn := func(message string, priority Priority, vars map[string]string) error {
+			var lastVars Fields
+			sendFn := func(message string, priority Priority, vars Fields) error {
 				lastVars = vars
 				return nil
 			}
@@ -147,11 +150,11 @@ func TestJournalWriterTagTemplate(t *testing.T) {
 
 			w.Write(mergedMessage{Line: []byte("x"), Source: "stdout", TimeNano: 1000}, PriInfo, []byte("x"), nil)
 
-			if lastVars["SYSLOG_IDENTIFIER"] != tt.wantTag {
-				t.Errorf("SYSLOG_IDENTIFIER = %q, want %q", lastVars["SYSLOG_IDENTIFIER"], tt.wantTag)
+			if lastVars.Get("SYSLOG_IDENTIFIER") != tt.wantTag {
+				t.Errorf("SYSLOG_IDENTIFIER = %q, want %q", lastVars.Get("SYSLOG_IDENTIFIER"), tt.wantTag)
 			}
-			if lastVars["CONTAINER_TAG"] != tt.wantTag {
-				t.Errorf("CONTAINER_TAG = %q, want %q", lastVars["CONTAINER_TAG"], tt.wantTag)
+			if lastVars.Get("CONTAINER_TAG") != tt.wantTag {
+				t.Errorf("CONTAINER_TAG = %q, want %q", lastVars.Get("CONTAINER_TAG"), tt.wantTag)
 			}
 		})
 	}
@@ -168,8 +171,8 @@ func TestJournalWriterFieldExtraction(t *testing.T) {
 		ContainerName: "/testcontainer",
 	})
 
-	var lastVars map[string]string
-	sendFn := func(message string, priority Priority, vars map[string]string) error {
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
 		lastVars = vars
 		return nil
 	}
@@ -186,11 +189,11 @@ func TestJournalWriterFieldExtraction(t *testing.T) {
 		t.Fatalf("Write: %v", err)
 	}
 
-	if lastVars["REQUEST_ID"] != "abc123" {
-		t.Errorf("REQUEST_ID = %q, want %q", lastVars["REQUEST_ID"], "abc123")
+	if lastVars.Get("REQUEST_ID") != "abc123" {
+		t.Errorf("REQUEST_ID = %q, want %q", lastVars.Get("REQUEST_ID"), "abc123")
 	}
-	if lastVars["USER_ID"] != "42" {
-		t.Errorf("USER_ID = %q, want %q", lastVars["USER_ID"], "42")
+	if lastVars.Get("USER_ID") != "42" {
+		t.Errorf("USER_ID = %q, want %q", lastVars.Get("USER_ID"), "42")
 	}
 
 	// Test with only one field present
@@ -199,11 +202,11 @@ func TestJournalWriterFieldExtraction(t *testing.T) {
 		t.Fatalf("Write: %v", err)
 	}
 
-	if lastVars["REQUEST_ID"] != "xyz789" {
-		t.Errorf("REQUEST_ID = %q, want %q", lastVars["REQUEST_ID"], "xyz789")
+	if lastVars.Get("REQUEST_ID") != "xyz789" {
+		t.Errorf("REQUEST_ID = %q, want %q", lastVars.Get("REQUEST_ID"), "xyz789")
 	}
 	if _, ok := lastVars["USER_ID"]; ok {
-		t.Errorf("USER_ID should not be present, got %q", lastVars["USER_ID"])
+		t.Errorf("USER_ID should not be present, got %q", lastVars.Get("USER_ID"))
 	}
 
 	// Test with no fields matching
@@ -213,9 +216,176 @@ func TestJournalWriterFieldExtraction(t *testing.T) {
 	}
 
 	if _, ok := lastVars["REQUEST_ID"]; ok {
-		t.Errorf("REQUEST_ID should not be present, got %q", lastVars["REQUEST_ID"])
+		t.Errorf("REQUEST_ID should not be present, got %q", lastVars.Get("REQUEST_ID"))
 	}
 	if _, ok := lastVars["USER_ID"]; ok {
-		t.Errorf("USER_ID should not be present, got %q", lastVars["USER_ID"])
+		t.Errorf("USER_ID should not be present, got %q", lastVars.Get("USER_ID"))
+	}
+}
+
+func TestJournalWriterSourceTime(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{})
+	infoJSON, _ := json.Marshal(containerInfo{ContainerID: "abcdef123456789012345678"})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	docker := time.Date(2024, 1, 15, 10, 30, 50, 0, time.UTC)
+	app := time.Date(2024, 1, 15, 10, 30, 45, 123456000, time.UTC)
+	msg := mergedMessage{Line: []byte("x"), TimeNano: docker.UnixNano(), SourceTime: app.UnixNano()}
+	w.Write(msg, PriInfo, []byte("x"), nil)
+
+	if lastVars.Get("SOURCE_REALTIME_TIMESTAMP") != "1705314645123456" {
+		t.Errorf("SOURCE_REALTIME_TIMESTAMP = %q", lastVars.Get("SOURCE_REALTIME_TIMESTAMP"))
+	}
+	if got, _ := time.Parse(time.RFC3339Nano, lastVars.Get("SYSLOG_TIMESTAMP")); !got.Equal(app) {
+		t.Errorf("SYSLOG_TIMESTAMP = %q, want application time", lastVars.Get("SYSLOG_TIMESTAMP"))
+	}
+
+	msg.SourceTime = 0
+	w.Write(msg, PriInfo, []byte("x"), nil)
+	if _, ok := lastVars["SOURCE_REALTIME_TIMESTAMP"]; ok {
+		t.Error("SOURCE_REALTIME_TIMESTAMP should be absent without application time")
+	}
+}
+
+func TestJournalWriterRepeatedFields(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"field-ORDER":       `order=(\d+)`,
+		"field-ORDER-match": "all",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{ContainerID: "abcdef123456789012345678"})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	msg := mergedMessage{Line: []byte("x"), TimeNano: 1000}
+	w.Write(msg, PriInfo, []byte("shipped order=1 order=22"), Fields{"tags": {"web", "eu"}})
+
+	if got := lastVars["ORDER"]; !reflect.DeepEqual(got, []string{"1", "22"}) {
+		t.Errorf("ORDER = %q, want [1 22]", got)
+	}
+	if got := lastVars["JSON_TAGS"]; !reflect.DeepEqual(got, []string{"web", "eu"}) {
+		t.Errorf("JSON_TAGS = %q, want [web eu]", got)
+	}
+}
+
+func TestAppendJournalField(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"simple", "hello", "A=hello\n"},
+		{"empty", "", "A=\n"},
+		{"newline", "a\nb", "A\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			appendJournalField(&buf, "A", tt.value)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalWriterFieldSources(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"field-MAJOR":        `^v?(\d+)\.`,
+		"field-MAJOR-source": "label:version",
+		"field-LEVEL":        `level=(\w+)`,
+		"field-LEVEL-source": "raw",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{
+		ContainerID:     "abcdef123456789012345678",
+		ContainerLabels: map[string]string{"version": "3.1.0"},
+	})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	msg := mergedMessage{Line: []byte("level=warn disk low"), TimeNano: 1000}
+	w.Write(msg, PriWarning, []byte("disk low"), nil)
+
+	if lastVars.Get("MAJOR") != "3" {
+		t.Errorf("MAJOR = %q, want 3", lastVars.Get("MAJOR"))
+	}
+	if lastVars.Get("LEVEL") != "warn" {
+		t.Errorf("LEVEL = %q, want warn", lastVars.Get("LEVEL"))
+	}
+
+	// The raw source is the line as written, before json-split-arrays
+	msg = mergedMessage{Line: []byte(`{"msg":"a"}`), RawLine: []byte(`[{"msg":"a"}] level=info`), TimeNano: 1000}
+	w.Write(msg, PriInfo, []byte("a"), nil)
+	if lastVars.Get("LEVEL") != "info" {
+		t.Errorf("LEVEL = %q, want info", lastVars.Get("LEVEL"))
+	}
+}
+
+func TestJournalWriterProtectedFields(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"json-field-prefix": "",
+		"labels":            "app",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{
+		ContainerID:     "abcdef123456789012345678",
+		ContainerName:   "/web",
+		ContainerLabels: map[string]string{"app": "shop"},
+	})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	jsonFields := Fields{
+		"container_name":    {"spoof"},
+		"syslog_identifier": {"sshd"},
+		"priority":          {"0"},
+		"message":           {"other"},
+		"app":               {"evil"},
+		"user":              {"bob"},
+	}
+	w.Write(mergedMessage{Line: []byte("hi"), TimeNano: 1000}, PriInfo, []byte("hi"), jsonFields)
+
+	want := map[string]string{"CONTAINER_NAME": "web", "SYSLOG_IDENTIFIER": "web", "APP": "shop", "USER": "bob"}
+	for name, v := range want {
+		if got := lastVars[name]; len(got) != 1 || got[0] != v {
+			t.Errorf("%s = %q, want [%s]", name, got, v)
+		}
+	}
+	for _, name := range []string{"PRIORITY", "MESSAGE"} {
+		if _, ok := lastVars[name]; ok {
+			t.Errorf("%s should not be in vars", name)
+		}
 	}
 }
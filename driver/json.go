@@ -1,16 +1,26 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // JSONParsedLog represents a successfully parsed JSON log line.
 type JSONParsedLog struct {
-	Level       string            // Extracted level/severity value
-	Message     string            // Extracted message body
-	ExtraFields map[string]string // Other fields to add as JSON_*
+	Level       string    // Extracted level/severity value
+	LevelKey    string    // JSON key the level was taken from
+	Time        time.Time // Parsed entry time (zero if none)
+	Message     string    // Extracted message body
+	Prefix      string    // Text before the JSON object (json-prefix-regex)
+	ExtraFields Fields    // Other fields to add as JSON_*
 }
 
 // ParseJSONLog attempts to parse a log line as JSON.
@@ -20,33 +30,79 @@ func ParseJSONLog(cfg *Config, line []byte) (*JSONParsedLog, bool) {
 		return nil, false
 	}
 
+	// Skip a text prefix before the object
+	var prefix string
+	if cfg.JSONPrefixRegex != nil {
+		if loc := cfg.JSONPrefixRegex.FindIndex(line); loc != nil {
+			prefix = strings.TrimSpace(string(line[:loc[1]]))
+			line = line[loc[1]:]
+		}
+	}
+
 	// Try to unmarshal as JSON object
 	var obj map[string]interface{}
-	if err := json.Unmarshal(line, &obj); err != nil {
-		return nil, false
+	if err := decodeJSONObject(line, &obj); err != nil {
+		s, ok := unwrapJSONString(cfg, line)
+		if !ok || decodeJSONObject([]byte(s), &obj) != nil {
+			return nil, false
+		}
 	}
 
 	result := &JSONParsedLog{
-		ExtraFields: make(map[string]string),
+		Prefix:      prefix,
+		ExtraFields: make(Fields),
 	}
 
 	// Extract level/severity (first match wins)
 	for _, key := range cfg.JSONLevelKeys {
-		if val, ok := obj[key]; ok {
-			if str, ok := val.(string); ok {
-				result.Level = str
-				delete(obj, key) // Don't duplicate in extra fields
-				break
+		if val, ok := lookupJSONPath(obj, key); ok {
+			switch level := val.(type) {
+			case string:
+				result.Level = level
+			case json.Number:
+				// Numeric levels need a level-scheme or level-map, otherwise
+				// the value is kept as an ordinary field
+				n := formatNumber(level)
+				if _, ok := cfg.LevelToPriority(n); !ok {
+					continue
+				}
+				result.Level = n
+			default:
+				continue
 			}
+			result.LevelKey = key
+			deleteJSONPath(obj, key) // Don't duplicate in extra fields
+			break
 		}
 	}
 
 	// Extract message (first match wins)
 	for _, key := range cfg.JSONMessageKeys {
-		if val, ok := obj[key]; ok {
+		if val, ok := lookupJSONPath(obj, key); ok {
 			if str, ok := val.(string); ok {
 				result.Message = str
-				delete(obj, key) // Don't duplicate in extra fields
+				deleteJSONPath(obj, key) // Don't duplicate in extra fields
+				break
+			}
+		}
+	}
+
+	// Append error/stack values to the message (all matches, in order)
+	for _, key := range cfg.JSONAppendKeys {
+		if val, ok := lookupJSONPath(obj, key); ok {
+			if str, ok := jsonValueString(val); ok && str != "" && result.Message != "" {
+				result.Message = appendMessage(result.Message, str)
+			}
+			deleteJSONPath(obj, key)
+		}
+	}
+
+	// Extract entry time (first parseable match wins)
+	for _, key := range cfg.JSONTimeKeys {
+		if val, ok := lookupJSONPath(obj, key); ok {
+			if t, ok := parseJSONTime(val, cfg.TimestampLocation); ok {
+				result.Time = t
+				deleteJSONPath(obj, key) // Don't duplicate in extra fields
 				break
 			}
 		}
@@ -59,30 +115,249 @@ func ParseJSONLog(cfg *Config, line []byte) (*JSONParsedLog, bool) {
 
 	// Flatten remaining fields
 	for k, v := range obj {
-		// Convert value to string
-		var strVal string
-		switch val := v.(type) {
-		case string:
-			strVal = val
-		case float64:
-			strVal = formatFloat(val)
-		case bool:
-			strVal = formatBool(val)
-		case nil:
-			continue // Skip null values
-		default:
-			// For nested objects/arrays, marshal back to JSON string
-			if b, err := json.Marshal(val); err == nil {
-				strVal = string(b)
-			} else {
-				continue // Skip if can't marshal
+		if nested, ok := v.(map[string]interface{}); ok && cfg.JSONFlatten && cfg.JSONFlattenDepth > 1 {
+			flattenJSON(result.ExtraFields, k, nested, 2, cfg.JSONFlattenDepth)
+			continue
+		}
+		if values, ok := jsonFieldValues(v); ok {
+			result.ExtraFields[k] = values
+		}
+	}
+
+	return result, true
+}
+
+// appendMessage adds an appended key value on a new line below msg.
+func appendMessage(msg, value string) string {
+	return strings.TrimRight(msg, "\n") + "\n" + value
+}
+
+// SplitJSONArray splits a line holding a JSON array of objects into one
+// message per object. All of them keep the Docker timestamp and get a shared
+// BATCH_ID field. Returns false if splitting is disabled or the line is not
+// a non-empty array of objects.
+func SplitJSONArray(cfg *Config, msg mergedMessage) ([]mergedMessage, bool) {
+	if !cfg.JSONSplitArrays {
+		return nil, false
+	}
+	line := bytes.TrimSpace(msg.Line)
+	if len(line) == 0 || line[0] != '[' {
+		return nil, false
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(line, &elems); err != nil || len(elems) == 0 {
+		return nil, false
+	}
+	for _, e := range elems {
+		if e = bytes.TrimSpace(e); len(e) == 0 || e[0] != '{' {
+			return nil, false
+		}
+	}
+
+	batchID := fmt.Sprintf("%016x", rand.Uint64())
+	msgs := make([]mergedMessage, len(elems))
+	for i, e := range elems {
+		m := msg
+		m.Line = e
+		m.RawLine = msg.raw()
+		m.Fields = nil
+		for k, v := range msg.Fields {
+			m.setField(k, v)
+		}
+		m.setField("BATCH_ID", batchID)
+		msgs[i] = m
+	}
+	return msgs, true
+}
+
+// decodeJSONObject unmarshals a JSON object like json.Unmarshal, but keeps
+// numbers as json.Number so large integers (e.g. epoch nanoseconds and IDs)
+// aren't rounded to float64.
+func decodeJSONObject(data []byte, obj *map[string]interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(obj); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// unwrapJSONString decodes a line that is a JSON string, as written by log
+// shippers that double-encode JSON. Only one level is unwrapped.
+func unwrapJSONString(cfg *Config, line []byte) (string, bool) {
+	if !cfg.JSONUnwrapString {
+		return "", false
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) < 2 || line[0] != '"' {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(line, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// jsonValueString converts a JSON value to a field value. Nested objects and
+// arrays are serialized as JSON. Returns false for null values.
+func jsonValueString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case json.Number:
+		return formatNumber(val), true
+	case bool:
+		return formatBool(val), true
+	case nil:
+		return "", false // Skip null values
+	default:
+		// For nested objects/arrays, marshal back to JSON string
+		b, err := json.Marshal(val)
+		if err != nil {
+			return "", false // Skip if can't marshal
+		}
+		return string(b), true
+	}
+}
+
+// jsonFieldValues converts a JSON value to field values. Non-empty arrays of
+// strings, numbers and booleans become repeated values, other values are
+// converted by jsonValueString.
+func jsonFieldValues(v interface{}) ([]string, bool) {
+	if arr, ok := v.([]interface{}); ok && len(arr) > 0 {
+		values := make([]string, 0, len(arr))
+		for _, e := range arr {
+			switch e.(type) {
+			case string, json.Number, bool:
+				s, _ := jsonValueString(e)
+				values = append(values, s)
+			default:
+				return jsonValueSlice(v)
 			}
 		}
+		return values, true
+	}
+	return jsonValueSlice(v)
+}
 
-		result.ExtraFields[k] = strVal
+func jsonValueSlice(v interface{}) ([]string, bool) {
+	s, ok := jsonValueString(v)
+	if !ok {
+		return nil, false
 	}
+	return []string{s}, true
+}
 
-	return result, true
+// flattenJSON adds the members of a nested object as dotted keys (e.g.
+// "http.status"). Objects nested deeper than maxDepth path segments are
+// serialized as JSON instead.
+func flattenJSON(fields Fields, prefix string, obj map[string]interface{}, depth, maxDepth int) {
+	for k, v := range obj {
+		key := prefix + "." + k
+		if nested, ok := v.(map[string]interface{}); ok && depth < maxDepth {
+			flattenJSON(fields, key, nested, depth+1, maxDepth)
+			continue
+		}
+		if values, ok := jsonFieldValues(v); ok {
+			fields[key] = values
+		}
+	}
+}
+
+// lookupJSONPath finds a key in a JSON object. A key containing dots is
+// first tried as a literal key, then as a path into nested objects
+// (e.g. "log.level" matches {"log":{"level":"info"}}).
+func lookupJSONPath(obj map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := obj[key]; ok {
+		return v, true
+	}
+	head, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return nil, false
+	}
+	nested, ok := obj[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupJSONPath(nested, rest)
+}
+
+// deleteJSONPath removes a key found by lookupJSONPath. Nested objects left
+// empty are removed as well.
+func deleteJSONPath(obj map[string]interface{}, key string) {
+	if _, ok := obj[key]; ok {
+		delete(obj, key)
+		return
+	}
+	head, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return
+	}
+	nested, ok := obj[head].(map[string]interface{})
+	if !ok {
+		return
+	}
+	deleteJSONPath(nested, rest)
+	if len(nested) == 0 {
+		delete(obj, head)
+	}
+}
+
+// parseJSONTime parses a JSON timestamp value. Strings are parsed as RFC 3339
+// or one of the built-in timestamp formats (zone-less ones in loc). Numbers,
+// and numeric strings, are epoch values whose unit (seconds, milliseconds,
+// microseconds or nanoseconds) is detected from their magnitude.
+func parseJSONTime(v interface{}, loc *time.Location) (time.Time, bool) {
+	switch val := v.(type) {
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return epochTime(float64(n), n)
+		}
+		if f, err := val.Float64(); err == nil {
+			return epochTime(f, 0)
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return t, true
+		}
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return epochTime(float64(n), n)
+		}
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return epochTime(f, 0)
+		}
+		return parseTimestamp([]byte(val), loc, time.Now())
+	}
+	return time.Time{}, false
+}
+
+// epochTime converts an epoch value of unknown unit to a time. Values below
+// 1e11 are seconds (until year 5138), below 1e14 milliseconds, below 1e17
+// microseconds, and nanoseconds above that. If exact is non-zero it is used
+// instead of f, to keep full nanosecond precision.
+func epochTime(f float64, exact int64) (time.Time, bool) {
+	if f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return time.Time{}, false
+	}
+	switch {
+	case f < 1e11:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), true
+	case f < 1e14:
+		return time.UnixMicro(int64(math.Round(f * 1e3))), true
+	case f < 1e17:
+		return time.UnixMicro(int64(math.Round(f))), true
+	case exact != 0:
+		return time.Unix(0, exact), true
+	case f < math.MaxInt64:
+		return time.Unix(0, int64(f)), true
+	}
+	return time.Time{}, false
 }
 
 // JSONLevelToPriority maps a JSON level string to a syslog priority.
@@ -110,6 +385,19 @@ func JSONLevelToPriority(level string) (Priority, bool) {
 	}
 }
 
+// formatNumber formats a JSON number. Integers are kept exact, other numbers
+// are formatted like formatFloat, e.g. 1.50 and 1e3 become 1.5 and 1000.
+func formatNumber(n json.Number) string {
+	if i, err := n.Int64(); err == nil {
+		return strconv.FormatInt(i, 10)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return n.String()
+	}
+	return formatFloat(f)
+}
+
 func formatFloat(f float64) string {
 	// If integer, format without decimal
 	if f == float64(int64(f)) {
@@ -18,9 +18,9 @@ func TestJSONParsingIntegration(t *testing.T) {
 
 	var lastMsg string
 	var lastPri Priority
-	var lastVars map[string]string
+	var lastVars Fields
 
-	sendFn := func(message string, priority Priority, vars map[string]string) error {
+	sendFn := func(message string, priority Priority, vars Fields) error {
 		lastMsg = message
 		lastPri = priority
 		lastVars = vars
@@ -59,11 +59,11 @@ func TestJSONParsingIntegration(t *testing.T) {
 	}
 
 	// Verify JSON fields are present with JSON_ prefix
-	if lastVars["JSON_REQUEST_ID"] != "abc123" {
-		t.Errorf("JSON_REQUEST_ID = %q, want %q", lastVars["JSON_REQUEST_ID"], "abc123")
+	if lastVars.Get("JSON_REQUEST_ID") != "abc123" {
+		t.Errorf("JSON_REQUEST_ID = %q, want %q", lastVars.Get("JSON_REQUEST_ID"), "abc123")
 	}
-	if lastVars["JSON_RETRY_COUNT"] != "3" {
-		t.Errorf("JSON_RETRY_COUNT = %q, want %q", lastVars["JSON_RETRY_COUNT"], "3")
+	if lastVars.Get("JSON_RETRY_COUNT") != "3" {
+		t.Errorf("JSON_RETRY_COUNT = %q, want %q", lastVars.Get("JSON_RETRY_COUNT"), "3")
 	}
 
 	// Verify level and message are NOT duplicated in extra fields
@@ -111,8 +111,8 @@ func TestJSONFieldSanitization(t *testing.T) {
 		ContainerName: "/testcontainer",
 	})
 
-	var lastVars map[string]string
-	sendFn := func(message string, priority Priority, vars map[string]string) error {
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
 		lastVars = vars
 		return nil
 	}
@@ -147,8 +147,104 @@ func TestJSONFieldSanitization(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		if got := lastVars[tt.field]; got != tt.want {
+		if got := lastVars.Get(tt.field); got != tt.want {
 			t.Errorf("%s = %q, want %q", tt.field, got, tt.want)
 		}
 	}
 }
+
+// TestJSONFlattenIntegration verifies that flattened keys become filterable journal fields.
+func TestJSONFlattenIntegration(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":   "true",
+		"json-flatten": "true",
+	})
+
+	infoJSON, _ := json.Marshal(containerInfo{
+		ContainerID:   "test123",
+		ContainerName: "/testcontainer",
+	})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	msg := mergedMessage{Line: []byte(`{"msg":"request failed","http":{"status":500,"method":"POST"}}`), Source: "stdout", TimeNano: 1000}
+	parsed, ok := ParseJSONLog(cfg, msg.Line)
+	if !ok {
+		t.Fatal("Expected JSON to be parsed")
+	}
+	if err := w.Write(msg, PriErr, []byte(parsed.Message), parsed.ExtraFields); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if lastVars.Get("JSON_HTTP_STATUS") != "500" {
+		t.Errorf("JSON_HTTP_STATUS = %q, want 500", lastVars.Get("JSON_HTTP_STATUS"))
+	}
+	if lastVars.Get("JSON_HTTP_METHOD") != "POST" {
+		t.Errorf("JSON_HTTP_METHOD = %q, want POST", lastVars.Get("JSON_HTTP_METHOD"))
+	}
+	if _, ok := lastVars["JSON_HTTP"]; ok {
+		t.Error("JSON_HTTP should not be present when flattening")
+	}
+}
+
+// TestJSONFieldMappingIntegration verifies mapped, filtered and prefixed JSON fields.
+func TestJSONFieldMappingIntegration(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":          "true",
+		"json-flatten":        "true",
+		"json-field-map":      "trace_id:TRACE_ID,user.id:USER_ID",
+		"json-fields-exclude": "internal_*",
+		"json-field-prefix":   "APP_",
+	})
+
+	infoJSON, _ := json.Marshal(containerInfo{
+		ContainerID:   "test123",
+		ContainerName: "/testcontainer",
+	})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	line := `{"msg":"login","trace_id":"abc","user":{"id":42,"role":"admin"},"internal_seq":7}`
+	msg := mergedMessage{Line: []byte(line), Source: "stdout", TimeNano: 1000}
+	parsed, ok := ParseJSONLog(cfg, msg.Line)
+	if !ok {
+		t.Fatal("Expected JSON to be parsed")
+	}
+	if err := w.Write(msg, PriInfo, []byte(parsed.Message), parsed.ExtraFields); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	want := map[string]string{
+		"TRACE_ID":      "abc",
+		"USER_ID":       "42",
+		"APP_USER_ROLE": "admin",
+	}
+	for k, v := range want {
+		if lastVars.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, lastVars.Get(k), v)
+		}
+	}
+	for _, k := range []string{"APP_INTERNAL_SEQ", "JSON_TRACE_ID", "APP_TRACE_ID"} {
+		if _, ok := lastVars[k]; ok {
+			t.Errorf("%s should not be present", k)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package driver
 
 import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseJSONLog(t *testing.T) {
@@ -79,6 +84,13 @@ func TestParseJSONLog(t *testing.T) {
 			wantMessage: "test",
 			wantFields:  map[string]string{"pi": "3.14", "count": "100"},
 		},
+		{
+			name:        "JSON with large integers",
+			line:        `{"message":"test","id":9007199254740993}`,
+			wantOK:      true,
+			wantMessage: "test",
+			wantFields:  map[string]string{"id": "9007199254740993"},
+		},
 		{
 			name:   "JSON with no message field",
 			line:   `{"level":"error","request_id":"123"}`,
@@ -128,7 +140,7 @@ func TestParseJSONLog(t *testing.T) {
 				}
 
 				for k, v := range tt.wantFields {
-					if got := parsed.ExtraFields[k]; got != v {
+					if got := parsed.ExtraFields.Get(k); got != v {
 						t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
 					}
 				}
@@ -211,3 +223,470 @@ func TestSanitizeFieldName(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJSONLogFlatten(t *testing.T) {
+	line := `{"msg":"done","http":{"status":500,"request":{"method":"GET","headers":{"host":"x"}}},"tags":["a","b"],"empty":{}}`
+
+	tests := []struct {
+		name       string
+		opts       map[string]string
+		wantFields map[string]string
+	}{
+		{
+			name: "disabled",
+			opts: map[string]string{},
+			wantFields: map[string]string{
+				"http":  `{"request":{"headers":{"host":"x"},"method":"GET"},"status":500}`,
+				"tags":  "a,b",
+				"empty": `{}`,
+			},
+		},
+		{
+			name: "default depth",
+			opts: map[string]string{"json-flatten": "true"},
+			wantFields: map[string]string{
+				"http.status":               "500",
+				"http.request.method":       "GET",
+				"http.request.headers.host": "x",
+				"tags":                      "a,b",
+			},
+		},
+		{
+			name: "depth 2",
+			opts: map[string]string{"json-flatten": "true", "json-flatten-depth": "2"},
+			wantFields: map[string]string{
+				"http.status":  "500",
+				"http.request": `{"headers":{"host":"x"},"method":"GET"}`,
+				"tags":         "a,b",
+			},
+		},
+		{
+			name: "depth 1",
+			opts: map[string]string{"json-flatten": "true", "json-flatten-depth": "1"},
+			wantFields: map[string]string{
+				"http":  `{"request":{"headers":{"host":"x"},"method":"GET"},"status":500}`,
+				"tags":  "a,b",
+				"empty": `{}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := map[string]string{"parse-json": "true"}
+			for k, v := range tt.opts {
+				opts[k] = v
+			}
+			parsed, ok := ParseJSONLog(mustConfig(t, opts), []byte(line))
+			if !ok {
+				t.Fatal("expected JSON to be parsed")
+			}
+			if len(parsed.ExtraFields) != len(tt.wantFields) {
+				t.Errorf("got fields %v, want %v", parsed.ExtraFields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if got := strings.Join(parsed.ExtraFields[k], ","); got != v {
+					t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJSONLogArrays(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-json": "true"})
+	line := `{"msg":"x","tags":["web","eu",1,true],"mixed":["a",{"b":1}],"none":[],"nested":[["a"]]}`
+	parsed, ok := ParseJSONLog(cfg, []byte(line))
+	if !ok {
+		t.Fatal("expected JSON to be parsed")
+	}
+	want := map[string][]string{
+		"tags":   {"web", "eu", "1", "true"},
+		"mixed":  {`["a",{"b":1}]`},
+		"none":   {`[]`},
+		"nested": {`[["a"]]`},
+	}
+	for k, v := range want {
+		if got := parsed.ExtraFields[k]; !reflect.DeepEqual(got, v) {
+			t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestParseJSONLogDottedKeys(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":        "true",
+		"json-level-keys":   "log.level,level",
+		"json-message-keys": "error.message,message",
+	})
+
+	tests := []struct {
+		name        string
+		line        string
+		wantLevel   string
+		wantKey     string
+		wantMessage string
+		wantFields  map[string]string
+	}{
+		{
+			name:        "nested paths",
+			line:        `{"log":{"level":"error","logger":"db"},"error":{"message":"timeout"}}`,
+			wantLevel:   "error",
+			wantKey:     "log.level",
+			wantMessage: "timeout",
+			wantFields:  map[string]string{"log": `{"logger":"db"}`},
+		},
+		{
+			name:        "literal dotted keys",
+			line:        `{"log.level":"warn","error.message":"retrying"}`,
+			wantLevel:   "warn",
+			wantKey:     "log.level",
+			wantMessage: "retrying",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:        "fallback to plain keys",
+			line:        `{"level":"info","message":"ok","log":"x"}`,
+			wantLevel:   "info",
+			wantKey:     "level",
+			wantMessage: "ok",
+			wantFields:  map[string]string{"log": "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, ok := ParseJSONLog(cfg, []byte(tt.line))
+			if !ok {
+				t.Fatal("expected JSON to be parsed")
+			}
+			if parsed.Level != tt.wantLevel || parsed.LevelKey != tt.wantKey {
+				t.Errorf("Level = %q (%q), want %q (%q)", parsed.Level, parsed.LevelKey, tt.wantLevel, tt.wantKey)
+			}
+			if parsed.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", parsed.Message, tt.wantMessage)
+			}
+			if len(parsed.ExtraFields) != len(tt.wantFields) {
+				t.Errorf("got fields %v, want %v", parsed.ExtraFields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if got := parsed.ExtraFields.Get(k); got != v {
+					t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJSONTime(t *testing.T) {
+	want := time.Date(2024, 1, 15, 10, 30, 45, 123000000, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   time.Time
+		wantOK bool
+	}{
+		{"RFC 3339", "2024-01-15T10:30:45.123Z", want, true},
+		{"RFC 3339 offset", "2024-01-15T12:30:45.123+02:00", want, true},
+		{"zone-less ISO", "2024-01-15 10:30:45.123", want, true},
+		{"epoch seconds", json.Number("1705314645.123"), want, true},
+		{"epoch seconds string", "1705314645.123", want, true},
+		{"epoch millis", json.Number("1705314645123"), want, true},
+		{"epoch micros", json.Number("1705314645123000"), want, true},
+		{"epoch nanos string", "1705314645123000000", want, true},
+		{"epoch nanos", json.Number("1705314645123000000"), want, true},
+		{"epoch nanos float", json.Number("1.705314645123e18"), want, true},
+		{"garbage", "yesterday", time.Time{}, false},
+		{"negative", json.Number("-1"), time.Time{}, false},
+		{"bool", true, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseJSONTime(tt.value, time.UTC)
+			if ok != tt.wantOK {
+				t.Fatalf("parseJSONTime(%v) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			// Fractional epochs lose sub-microsecond precision
+			if ok && got.Sub(tt.want).Abs() > time.Microsecond {
+				t.Errorf("parseJSONTime(%v) = %v, want %v", tt.value, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLogTimeKeys(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":     "true",
+		"json-time-keys": "@timestamp,ts,time",
+	})
+
+	parsed, ok := ParseJSONLog(cfg, []byte(`{"msg":"hi","ts":1705314645,"time":"not a time","other":"x"}`))
+	if !ok {
+		t.Fatal("expected JSON to be parsed")
+	}
+	if !parsed.Time.Equal(time.Unix(1705314645, 0)) {
+		t.Errorf("Time = %v", parsed.Time)
+	}
+	if _, ok := parsed.ExtraFields["ts"]; ok {
+		t.Error("ts should be removed from extra fields")
+	}
+	if parsed.ExtraFields.Get("time") != "not a time" {
+		t.Errorf("unparseable time key should be kept, got %v", parsed.ExtraFields)
+	}
+
+	// Numeric nanoseconds keep full precision
+	parsed, _ = ParseJSONLog(cfg, []byte(`{"msg":"hi","ts":1705314645123456789}`))
+	if want := time.Unix(0, 1705314645123456789); !parsed.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", parsed.Time, want)
+	}
+
+	// Without json-time-keys, time values stay ordinary fields
+	cfg = mustConfig(t, map[string]string{"parse-json": "true"})
+	parsed, _ = ParseJSONLog(cfg, []byte(`{"msg":"hi","ts":1705314645}`))
+	if !parsed.Time.IsZero() || parsed.ExtraFields.Get("ts") != "1705314645" {
+		t.Errorf("Time = %v, fields = %v", parsed.Time, parsed.ExtraFields)
+	}
+}
+
+func TestParseJSONLogPrefixAndUnwrap(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        map[string]string
+		line        string
+		wantOK      bool
+		wantMessage string
+		wantPrefix  string
+	}{
+		{
+			name:        "prefix skipped",
+			opts:        map[string]string{"json-prefix-regex": `^\S+ \S+: `},
+			line:        `2024-01-15T10:30:45Z app[web.1]: {"level":"info","msg":"hello"}`,
+			wantOK:      true,
+			wantMessage: "hello",
+			wantPrefix:  "2024-01-15T10:30:45Z app[web.1]:",
+		},
+		{
+			name:        "unanchored regex takes text up to match end",
+			opts:        map[string]string{"json-prefix-regex": `\]: `},
+			line:        `app[web.1]: {"msg":"hello"}`,
+			wantOK:      true,
+			wantMessage: "hello",
+			wantPrefix:  "app[web.1]:",
+		},
+		{
+			name:        "regex not matching leaves line unchanged",
+			opts:        map[string]string{"json-prefix-regex": `^\S+ \S+: `},
+			line:        `{"msg":"hello"}`,
+			wantOK:      true,
+			wantMessage: "hello",
+		},
+		{
+			name:   "prefix without regex fails",
+			opts:   nil,
+			line:   `app: {"msg":"hello"}`,
+			wantOK: false,
+		},
+		{
+			name:        "string-wrapped JSON",
+			opts:        map[string]string{"json-unwrap-string": "true"},
+			line:        `"{\"level\":\"warn\",\"msg\":\"wrapped\"}"`,
+			wantOK:      true,
+			wantMessage: "wrapped",
+		},
+		{
+			name:   "string-wrapped JSON without unwrap",
+			opts:   nil,
+			line:   `"{\"msg\":\"wrapped\"}"`,
+			wantOK: false,
+		},
+		{
+			name:   "only one level is unwrapped",
+			opts:   map[string]string{"json-unwrap-string": "true"},
+			line:   `"\"{\\\"msg\\\":\\\"twice\\\"}\""`,
+			wantOK: false,
+		},
+		{
+			name:   "plain JSON string",
+			opts:   map[string]string{"json-unwrap-string": "true"},
+			line:   `"just text"`,
+			wantOK: false,
+		},
+		{
+			name:        "prefix and unwrap",
+			opts:        map[string]string{"json-prefix-regex": `^\S+: `, "json-unwrap-string": "true"},
+			line:        `shipper: "{\"msg\":\"both\"}"`,
+			wantOK:      true,
+			wantMessage: "both",
+			wantPrefix:  "shipper:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := map[string]string{"parse-json": "true"}
+			for k, v := range tt.opts {
+				opts[k] = v
+			}
+			cfg := mustConfig(t, opts)
+
+			parsed, ok := ParseJSONLog(cfg, []byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("ParseJSONLog() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if parsed.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", parsed.Message, tt.wantMessage)
+			}
+			if parsed.Prefix != tt.wantPrefix {
+				t.Errorf("Prefix = %q, want %q", parsed.Prefix, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestParseJSONLogAppendKeys(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":       "true",
+		"json-append-keys": "error,stack,err.stack",
+	})
+
+	tests := []struct {
+		name        string
+		line        string
+		wantOK      bool
+		wantMessage string
+		wantFields  map[string]string
+	}{
+		{
+			name:        "stack appended and unescaped",
+			line:        `{"msg":"request failed","stack":"Error: boom\n    at f (a.js:1)\n    at g (b.js:2)","id":"1"}`,
+			wantOK:      true,
+			wantMessage: "request failed\nError: boom\n    at f (a.js:1)\n    at g (b.js:2)",
+			wantFields:  map[string]string{"id": "1"},
+		},
+		{
+			name:        "multiple keys in order",
+			line:        `{"stack":"at main()","msg":"failed","error":"EOF"}`,
+			wantOK:      true,
+			wantMessage: "failed\nEOF\nat main()",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:        "dotted path",
+			line:        `{"msg":"oops","err":{"message":"x","stack":"TypeError: x\n    at y"}}`,
+			wantOK:      true,
+			wantMessage: "oops\nTypeError: x\n    at y",
+			wantFields:  map[string]string{"err": `{"message":"x"}`},
+		},
+		{
+			name:        "non-string value",
+			line:        `{"msg":"failed","error":{"code":5}}`,
+			wantOK:      true,
+			wantMessage: "failed\n{\"code\":5}",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:        "empty and null values dropped",
+			line:        `{"msg":"ok","error":"","stack":null}`,
+			wantOK:      true,
+			wantMessage: "ok",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:        "trailing newline in message",
+			line:        `{"msg":"failed\n","error":"EOF"}`,
+			wantOK:      true,
+			wantMessage: "failed\nEOF",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:   "no message",
+			line:   `{"error":"EOF"}`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, ok := ParseJSONLog(cfg, []byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("ParseJSONLog() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if parsed.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", parsed.Message, tt.wantMessage)
+			}
+			if len(parsed.ExtraFields) != len(tt.wantFields) {
+				t.Errorf("ExtraFields = %v, want %v", parsed.ExtraFields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if got := parsed.ExtraFields.Get(k); got != v {
+					t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitJSONArray(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-json": "true", "json-split-arrays": "true"})
+
+	msg := mergedMessage{
+		Line:     []byte(`[{"level":"info","msg":"a"}, {"level":"error","msg":"b","id":2}]`),
+		Source:   "stdout",
+		TimeNano: 12345,
+		Fields:   map[string]string{"EXISTING": "x"},
+	}
+	batch, ok := SplitJSONArray(cfg, msg)
+	if !ok || len(batch) != 2 {
+		t.Fatalf("SplitJSONArray() = %d messages, %v; want 2, true", len(batch), ok)
+	}
+	if string(batch[0].Line) != `{"level":"info","msg":"a"}` || string(batch[1].Line) != `{"level":"error","msg":"b","id":2}` {
+		t.Errorf("lines = %q, %q", batch[0].Line, batch[1].Line)
+	}
+	id := batch[0].Fields["BATCH_ID"]
+	if id == "" || batch[1].Fields["BATCH_ID"] != id {
+		t.Errorf("BATCH_ID = %q, %q; want equal and non-empty", id, batch[1].Fields["BATCH_ID"])
+	}
+	for i, m := range batch {
+		if m.TimeNano != 12345 || m.Source != "stdout" || m.Fields["EXISTING"] != "x" {
+			t.Errorf("message %d = %+v, want original metadata", i, m)
+		}
+		if !bytes.Equal(m.RawLine, msg.Line) {
+			t.Errorf("message %d RawLine = %q, want the whole array", i, m.RawLine)
+		}
+	}
+	if _, ok := msg.Fields["BATCH_ID"]; ok {
+		t.Error("original message fields were modified")
+	}
+
+	// Each batch gets its own ID
+	again, _ := SplitJSONArray(cfg, msg)
+	if again[0].Fields["BATCH_ID"] == id {
+		t.Error("BATCH_ID should differ between batches")
+	}
+
+	for _, line := range []string{
+		`{"msg":"object"}`,
+		`[]`,
+		`[{"msg":"a"}, "b"]`,
+		`[1, 2]`,
+		`[{"msg":"a"}`,
+		`plain text`,
+	} {
+		if _, ok := SplitJSONArray(cfg, mergedMessage{Line: []byte(line)}); ok {
+			t.Errorf("SplitJSONArray(%q) should not split", line)
+		}
+	}
+
+	off := mustConfig(t, map[string]string{"parse-json": "true"})
+	if _, ok := SplitJSONArray(off, msg); ok {
+		t.Error("SplitJSONArray() should not split when disabled")
+	}
+}
@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Built-in level schemes, selected with the level-scheme option.
+var levelSchemes = map[string]func(level string) (Priority, bool){
+	"pino":   pinoLevelToPriority,
+	"syslog": syslogLevelToPriority,
+	"dotnet": dotnetLevelToPriority,
+	"otel":   otelLevelToPriority,
+}
+
+// LevelToPriority maps a level value from a structured log line to a syslog
+// priority. It checks in order:
+// 1. level-map entries (case-insensitive)
+// 2. the level-scheme, if any
+// 3. the built-in level names (see JSONLevelToPriority)
+func (c *Config) LevelToPriority(level string) (Priority, bool) {
+	if pri, ok := c.LevelMap[strings.ToLower(level)]; ok {
+		return pri, true
+	}
+	if c.LevelScheme != "" {
+		if pri, ok := levelSchemes[c.LevelScheme](level); ok {
+			return pri, true
+		}
+	}
+	return JSONLevelToPriority(level)
+}
+
+// parseLevelMap parses comma-separated LEVEL:priority pairs.
+func parseLevelMap(s string) (map[string]Priority, error) {
+	m := make(map[string]Priority)
+	for _, pair := range strings.Split(s, ",") {
+		level, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		level, name = strings.TrimSpace(level), strings.TrimSpace(name)
+		if !ok || level == "" {
+			return nil, fmt.Errorf("invalid entry %q: must be LEVEL:priority", pair)
+		}
+		pri, err := parsePriorityName(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %q: %w", pair, err)
+		}
+		m[strings.ToLower(level)] = pri
+	}
+	return m, nil
+}
+
+// pinoLevelToPriority maps pino/bunyan numeric levels (trace=10, debug=20,
+// info=30, warn=40, error=50, fatal=60). Custom levels in between map to the
+// closest standard level below.
+func pinoLevelToPriority(level string) (Priority, bool) {
+	n, err := strconv.ParseFloat(level, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case n >= 60:
+		return PriCrit, true
+	case n >= 50:
+		return PriErr, true
+	case n >= 40:
+		return PriWarning, true
+	case n >= 30:
+		return PriInfo, true
+	default:
+		return PriDebug, true
+	}
+}
+
+// syslogLevelToPriority maps syslog numeric severities 0-7.
+func syslogLevelToPriority(level string) (Priority, bool) {
+	n, err := strconv.Atoi(level)
+	if err != nil || n < 0 || n > 7 {
+		return 0, false
+	}
+	return Priority(n), true
+}
+
+// dotnetLevelToPriority maps Microsoft.Extensions.Logging numeric levels
+// (Trace=0 .. Critical=5) and Serilog's Verbose level. Other .NET level
+// names (Information, Warning, Critical, etc.) are built-in.
+func dotnetLevelToPriority(level string) (Priority, bool) {
+	switch strings.ToLower(level) {
+	case "0", "1", "verbose":
+		return PriDebug, true
+	case "2":
+		return PriInfo, true
+	case "3":
+		return PriWarning, true
+	case "4":
+		return PriErr, true
+	case "5":
+		return PriCrit, true
+	}
+	return 0, false
+}
+
+// otelLevelToPriority maps OpenTelemetry SeverityNumber ranges (TRACE=1-4,
+// DEBUG=5-8, INFO=9-12, WARN=13-16, ERROR=17-20, FATAL=21-24).
+func otelLevelToPriority(level string) (Priority, bool) {
+	n, err := strconv.Atoi(level)
+	if err != nil || n < 1 || n > 24 {
+		return 0, false
+	}
+	switch {
+	case n >= 21:
+		return PriCrit, true
+	case n >= 17:
+		return PriErr, true
+	case n >= 13:
+		return PriWarning, true
+	case n >= 9:
+		return PriInfo, true
+	default:
+		return PriDebug, true
+	}
+}
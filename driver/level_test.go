@@ -0,0 +1,66 @@
+package driver
+
+import "testing"
+
+func TestLevelToPriority(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]string
+		level   string
+		wantPri Priority
+		wantOK  bool
+	}{
+		{"built-in name", nil, "warning", PriWarning, true},
+		{"unknown without map", nil, "SEVERE", 0, false},
+		{"numeric without scheme", nil, "50", 0, false},
+
+		{"level-map name", map[string]string{"level-map": "SEVERE:err,FINE:debug"}, "SEVERE", PriErr, true},
+		{"level-map case-insensitive", map[string]string{"level-map": "SEVERE:err,FINE:debug"}, "fine", PriDebug, true},
+		{"level-map numeric", map[string]string{"level-map": "50:err"}, "50", PriErr, true},
+		{"level-map overrides built-in", map[string]string{"level-map": "trace:info"}, "trace", PriInfo, true},
+		{"level-map falls back to built-in", map[string]string{"level-map": "SEVERE:err"}, "error", PriErr, true},
+
+		{"pino trace", map[string]string{"level-scheme": "pino"}, "10", PriDebug, true},
+		{"pino debug", map[string]string{"level-scheme": "pino"}, "20", PriDebug, true},
+		{"pino info", map[string]string{"level-scheme": "pino"}, "30", PriInfo, true},
+		{"pino warn", map[string]string{"level-scheme": "pino"}, "40", PriWarning, true},
+		{"pino error", map[string]string{"level-scheme": "pino"}, "50", PriErr, true},
+		{"pino fatal", map[string]string{"level-scheme": "pino"}, "60", PriCrit, true},
+		{"pino custom level", map[string]string{"level-scheme": "pino"}, "35", PriInfo, true},
+		{"pino names still work", map[string]string{"level-scheme": "pino"}, "warn", PriWarning, true},
+
+		{"syslog 0", map[string]string{"level-scheme": "syslog"}, "0", PriEmerg, true},
+		{"syslog 3", map[string]string{"level-scheme": "syslog"}, "3", PriErr, true},
+		{"syslog out of range", map[string]string{"level-scheme": "syslog"}, "8", 0, false},
+
+		{"dotnet trace", map[string]string{"level-scheme": "dotnet"}, "0", PriDebug, true},
+		{"dotnet information", map[string]string{"level-scheme": "dotnet"}, "2", PriInfo, true},
+		{"dotnet error", map[string]string{"level-scheme": "dotnet"}, "4", PriErr, true},
+		{"dotnet critical", map[string]string{"level-scheme": "dotnet"}, "5", PriCrit, true},
+		{"dotnet Critical name", map[string]string{"level-scheme": "dotnet"}, "Critical", PriCrit, true},
+		{"dotnet Information name", map[string]string{"level-scheme": "dotnet"}, "Information", PriInfo, true},
+		{"serilog Verbose", map[string]string{"level-scheme": "dotnet"}, "Verbose", PriDebug, true},
+
+		{"otel trace", map[string]string{"level-scheme": "otel"}, "1", PriDebug, true},
+		{"otel info", map[string]string{"level-scheme": "otel"}, "9", PriInfo, true},
+		{"otel warn", map[string]string{"level-scheme": "otel"}, "16", PriWarning, true},
+		{"otel error", map[string]string{"level-scheme": "otel"}, "17", PriErr, true},
+		{"otel fatal", map[string]string{"level-scheme": "otel"}, "24", PriCrit, true},
+		{"otel out of range", map[string]string{"level-scheme": "otel"}, "25", 0, false},
+
+		{"level-map before scheme", map[string]string{"level-scheme": "pino", "level-map": "30:notice"}, "30", PriNotice, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mustConfig(t, tt.opts)
+			pri, ok := cfg.LevelToPriority(tt.level)
+			if ok != tt.wantOK {
+				t.Fatalf("LevelToPriority(%q) ok = %v, want %v", tt.level, ok, tt.wantOK)
+			}
+			if ok && pri != tt.wantPri {
+				t.Errorf("LevelToPriority(%q) = %d, want %d", tt.level, pri, tt.wantPri)
+			}
+		})
+	}
+}
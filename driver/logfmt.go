@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ParseLogfmtLog attempts to parse a log line as logfmt (key=value pairs, as
+// written by slog's TextHandler or logrus). Level, message and time are taken
+// from the json-level-keys, json-message-keys and json-time-keys options,
+// json-append-keys are appended to the message, and the remaining pairs
+// become extra fields, just like ParseJSONLog.
+// Returns (nil, false) if the line is not logfmt or has no message key.
+func ParseLogfmtLog(cfg *Config, line []byte) (*JSONParsedLog, bool) {
+	if !cfg.ParseLogfmt || len(line) == 0 {
+		return nil, false
+	}
+
+	pairs, err := parseLogfmt(line)
+	if err != nil || len(pairs) == 0 {
+		return nil, false
+	}
+	obj := make(Fields, len(pairs))
+	for _, p := range pairs {
+		obj.Add(p.Key, p.Value) // Repeated keys become repeated fields
+	}
+
+	result := &JSONParsedLog{}
+
+	// Extract level (first match wins)
+	for _, key := range cfg.JSONLevelKeys {
+		if val := obj.Get(key); val != "" {
+			result.Level = val
+			result.LevelKey = key
+			delete(obj, key)
+			break
+		}
+	}
+
+	// Extract message (first match wins)
+	for _, key := range cfg.JSONMessageKeys {
+		if val, ok := obj[key]; ok {
+			result.Message = val[0]
+			delete(obj, key)
+			break
+		}
+	}
+
+	// Extract entry time (first parseable match wins)
+	for _, key := range cfg.JSONTimeKeys {
+		if val, ok := obj[key]; ok {
+			if t, ok := parseJSONTime(val[0], cfg.TimestampLocation); ok {
+				result.Time = t
+				delete(obj, key)
+				break
+			}
+		}
+	}
+
+	if result.Message == "" {
+		return nil, false
+	}
+	appendStringKeys(cfg, result, obj)
+	result.ExtraFields = obj
+	return result, true
+}
+
+// appendStringKeys appends the json-append-keys values in fields to the
+// message, in order, and removes them from fields.
+func appendStringKeys(cfg *Config, result *JSONParsedLog, fields Fields) {
+	for _, key := range cfg.JSONAppendKeys {
+		for _, val := range fields[key] {
+			if val != "" {
+				result.Message = appendMessage(result.Message, val)
+			}
+		}
+		delete(fields, key)
+	}
+}
+
+// logfmtPair is a single key=value pair of a logfmt line.
+type logfmtPair struct {
+	Key   string
+	Value string
+}
+
+var (
+	errLogfmtKey   = errors.New("logfmt: expected key")
+	errLogfmtEqual = errors.New("logfmt: expected '=' after key")
+	errLogfmtQuote = errors.New("logfmt: unterminated or invalid quoted value")
+)
+
+// parseLogfmt splits a line into key=value pairs. Keys are runs of printable
+// bytes other than '=' and '"'. Values are either unquoted (up to the next
+// space) or double-quoted with Go string escapes. Bare keys without a value
+// are rejected, so plain text lines fail to parse.
+func parseLogfmt(line []byte) ([]logfmtPair, error) {
+	var pairs []logfmtPair
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return pairs, nil
+		}
+
+		// Key
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		if i == start {
+			return nil, errLogfmtKey
+		}
+		key := string(line[start:i])
+		if !byteAt(line, i, '=') {
+			return nil, errLogfmtEqual
+		}
+		i++
+
+		// Value
+		var value string
+		if byteAt(line, i, '"') {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errLogfmtQuote
+			}
+			v, err := strconv.Unquote(string(line[i : end+1]))
+			if err != nil {
+				return nil, errLogfmtQuote
+			}
+			value = v
+			i = end + 1
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, errLogfmtQuote
+			}
+		} else {
+			start = i
+			for i < len(line) && !isSpace(line[i]) {
+				i++
+			}
+			value = string(line[start:i])
+		}
+		pairs = append(pairs, logfmtPair{key, value})
+	}
+}
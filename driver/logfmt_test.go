@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []logfmtPair
+		wantErr bool
+	}{
+		{
+			name: "unquoted values",
+			line: `level=info msg=started port=8080`,
+			want: []logfmtPair{{"level", "info"}, {"msg", "started"}, {"port", "8080"}},
+		},
+		{
+			name: "quoted value with spaces",
+			line: `msg="hello world" user=bob`,
+			want: []logfmtPair{{"msg", "hello world"}, {"user", "bob"}},
+		},
+		{
+			name: "escapes in quoted value",
+			line: `msg="say \"hi\"\tnow\\" path="C:\\tmp"`,
+			want: []logfmtPair{{"msg", "say \"hi\"\tnow\\"}, {"path", `C:\tmp`}},
+		},
+		{
+			name: "empty values",
+			line: `a= b="" c=1`,
+			want: []logfmtPair{{"a", ""}, {"b", ""}, {"c", "1"}},
+		},
+		{
+			name: "equals sign in unquoted value",
+			line: `query=a=b`,
+			want: []logfmtPair{{"query", "a=b"}},
+		},
+		{
+			name: "dotted keys and extra whitespace",
+			line: `  http.status=500   err.msg="not found"  `,
+			want: []logfmtPair{{"http.status", "500"}, {"err.msg", "not found"}},
+		},
+		{
+			name: "empty line",
+			line: ``,
+			want: nil,
+		},
+		{name: "plain text", line: `server started on port 8080`, wantErr: true},
+		{name: "bare key", line: `msg=hi debug`, wantErr: true},
+		{name: "missing key", line: `=value`, wantErr: true},
+		{name: "unterminated quote", line: `msg="hello`, wantErr: true},
+		{name: "trailing backslash in quote", line: `msg="hello\`, wantErr: true},
+		{name: "invalid escape", line: `msg="\q"`, wantErr: true},
+		{name: "text after quote", line: `msg="a"b`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogfmt([]byte(tt.line))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseLogfmt(%q) = %v, want error", tt.line, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLogfmt(%q) error: %v", tt.line, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseLogfmt(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("pair %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLogfmtLog(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-logfmt":   "true",
+		"json-time-keys": "time",
+	})
+
+	tests := []struct {
+		name        string
+		line        string
+		wantOK      bool
+		wantLevel   string
+		wantMessage string
+		wantTime    time.Time
+		wantFields  map[string]string
+	}{
+		{
+			name:        "slog TextHandler",
+			line:        `time=2024-03-01T12:00:00.000Z level=WARN msg="disk almost full" path=/var free=3%`,
+			wantOK:      true,
+			wantLevel:   "WARN",
+			wantMessage: "disk almost full",
+			wantTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			wantFields:  map[string]string{"path": "/var", "free": "3%"},
+		},
+		{
+			name:        "logrus text",
+			line:        `time="2024-03-01T12:00:00Z" level=error msg="connection refused" host=db`,
+			wantOK:      true,
+			wantLevel:   "error",
+			wantMessage: "connection refused",
+			wantTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			wantFields:  map[string]string{"host": "db"},
+		},
+		{
+			name:        "unparseable time is kept as field",
+			line:        `time=soon msg=hi`,
+			wantOK:      true,
+			wantMessage: "hi",
+			wantFields:  map[string]string{"time": "soon"},
+		},
+		{
+			name:   "no message key",
+			line:   `level=info user=bob`,
+			wantOK: false,
+		},
+		{
+			name:   "plain text",
+			line:   `ERROR something failed`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, ok := ParseLogfmtLog(cfg, []byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("ParseLogfmtLog() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if parsed.Level != tt.wantLevel {
+				t.Errorf("Level = %q, want %q", parsed.Level, tt.wantLevel)
+			}
+			if parsed.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", parsed.Message, tt.wantMessage)
+			}
+			if !parsed.Time.Equal(tt.wantTime) {
+				t.Errorf("Time = %v, want %v", parsed.Time, tt.wantTime)
+			}
+			if len(parsed.ExtraFields) != len(tt.wantFields) {
+				t.Errorf("ExtraFields = %v, want %v", parsed.ExtraFields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if got := parsed.ExtraFields.Get(k); got != v {
+					t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+
+	t.Run("append keys", func(t *testing.T) {
+		cfg := mustConfig(t, map[string]string{"parse-logfmt": "true", "json-append-keys": "err"})
+		parsed, ok := ParseLogfmtLog(cfg, []byte(`level=error msg="write failed" err="disk full\nretrying"`))
+		if !ok {
+			t.Fatal("ParseLogfmtLog() failed")
+		}
+		if want := "write failed\ndisk full\nretrying"; parsed.Message != want {
+			t.Errorf("Message = %q, want %q", parsed.Message, want)
+		}
+		if _, ok := parsed.ExtraFields["err"]; ok {
+			t.Error("appended key should not be an extra field")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := mustConfig(t, nil)
+		if _, ok := ParseLogfmtLog(cfg, []byte(`level=info msg=hi`)); ok {
+			t.Error("ParseLogfmtLog() should fail when parse-logfmt is off")
+		}
+	})
+}
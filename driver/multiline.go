@@ -11,7 +11,26 @@ type mergedMessage struct {
 	Line       []byte
 	Source     string
 	TimeNano   int64
-	JSONFields map[string]string // Extracted JSON fields (nil if not JSON)
+	JSONFields Fields            // Extracted JSON fields (nil if not JSON)
+	Fields     map[string]string // Pipeline-added journal fields (e.g. REPEAT_COUNT)
+	SourceTime int64             // Application timestamp (ns); 0 = use TimeNano
+	RawLine    []byte            // Line as written by the container; nil = Line
+}
+
+// raw returns the line as written by the container, before json-split-arrays.
+func (m *mergedMessage) raw() []byte {
+	if m.RawLine != nil {
+		return m.RawLine
+	}
+	return m.Line
+}
+
+// setField adds a pipeline field to the message.
+func (m *mergedMessage) setField(name, value string) {
+	if m.Fields == nil {
+		m.Fields = make(map[string]string)
+	}
+	m.Fields[name] = value
 }
 
 // multilineMerger buffers and merges consecutive continuation lines.
@@ -26,6 +45,7 @@ type multilineMerger struct {
 	timeNano   int64
 	timer      *time.Timer
 	hasData    bool
+	inRecord   bool // buffering a record until MultilineEnd
 	generation uint64
 }
 
@@ -39,7 +59,7 @@ func newMultilineMerger(cfg *Config, output func(mergedMessage)) *multilineMerge
 // AddLine processes a single reassembled log line.
 func (m *multilineMerger) AddLine(line []byte, source string, timeNano int64) {
 	// If multiline is disabled, pass through directly
-	if m.cfg.MultilineRegex == nil {
+	if m.cfg.MultilineRegex == nil && m.cfg.MultilineEnd == nil {
 		m.output(mergedMessage{
 			Line:     append([]byte(nil), line...),
 			Source:   source,
@@ -51,7 +71,12 @@ func (m *multilineMerger) AddLine(line []byte, source string, timeNano int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	isContinuation := m.cfg.MultilineRegex.Match(line)
+	// Records (e.g. XML events) are merged up to their end tag
+	if m.cfg.MultilineEnd != nil && m.addRecordLineLocked(line, source, timeNano) {
+		return
+	}
+
+	isContinuation := m.cfg.MultilineRegex != nil && m.cfg.MultilineRegex.Match(line)
 
 	if !isContinuation {
 		// This is a new message -- flush any buffered content first
@@ -99,6 +124,42 @@ func (m *multilineMerger) AddLine(line []byte, source string, timeNano int64) {
 	m.resetTimerLocked()
 }
 
+// addRecordLineLocked handles a line in record mode. A line matching
+// MultilineStart (but not MultilineEnd) opens a record, and all following
+// lines are appended until one matches MultilineEnd, which completes the
+// record. Returns false if the line is not part of a record.
+func (m *multilineMerger) addRecordLineLocked(line []byte, source string, timeNano int64) bool {
+	if m.inRecord {
+		if m.lineCount >= m.cfg.MultilineMaxLines ||
+			m.buf.Len()+len(m.cfg.MultilineSep)+len(line) > m.cfg.MultilineMaxBytes {
+			m.flushLocked() // Oversized record, handle line normally
+			return false
+		}
+		m.buf.WriteString(m.cfg.MultilineSep)
+		m.buf.Write(line)
+		m.lineCount++
+		if m.cfg.MultilineEnd.Match(line) {
+			m.flushLocked()
+		} else {
+			m.resetTimerLocked()
+		}
+		return true
+	}
+
+	if !m.cfg.MultilineStart.Match(line) || m.cfg.MultilineEnd.Match(line) {
+		return false
+	}
+	m.flushLocked()
+	m.buf.Write(line)
+	m.lineCount = 1
+	m.source = source
+	m.timeNano = timeNano
+	m.hasData = true
+	m.inRecord = true
+	m.resetTimerLocked()
+	return true
+}
+
 // Flush forces any buffered content to be emitted.
 func (m *multilineMerger) Flush() {
 	m.mu.Lock()
@@ -125,6 +186,7 @@ func (m *multilineMerger) flushLocked() {
 	m.buf.Reset()
 	m.lineCount = 0
 	m.hasData = false
+	m.inRecord = false
 
 	m.output(msg)
 }
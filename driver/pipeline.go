@@ -0,0 +1,180 @@
+package driver
+
+import (
+	"bytes"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// messageProcessor turns merged messages into journal entries. It holds the
+// per-container state of the parsing steps, and is not safe for concurrent
+// use (the multiline merger serializes its output).
+type messageProcessor struct {
+	cfg        *Config
+	container  tagData // container metadata for templates
+	timestamps *timestampStripper
+}
+
+func newMessageProcessor(cfg *Config, container tagData) *messageProcessor {
+	return &messageProcessor{
+		cfg:        cfg,
+		container:  container,
+		timestamps: newTimestampStripper(cfg),
+	}
+}
+
+// Process parses JSON, logfmt, XML or access log lines, strips timestamps,
+// detects the priority and renders the templates of a merged message,
+// returning the entry to be written.
+func (p *messageProcessor) Process(msg mergedMessage) journalEntry {
+	cfg := p.cfg
+	line := msg.Line
+	var jsonFields Fields
+	var priority Priority
+	var decision priorityDecision
+	priorityDetected := false
+
+	// Try JSON parsing first if enabled, then logfmt and XML
+	levelSource := prioritySourceJSON
+	parsed, ok := ParseJSONLog(cfg, line)
+	if !ok {
+		parsed, ok = ParseLogfmtLog(cfg, line)
+		levelSource = prioritySourceLogfmt
+	}
+	if !ok {
+		parsed, ok = ParseXMLLog(cfg, line)
+		levelSource = prioritySourceXML
+	}
+	if ok {
+		// Structured parsing succeeded
+		jsonFields = parsed.ExtraFields
+
+		// Keep the text prefix before the JSON object
+		if parsed.Prefix != "" && cfg.JSONPrefixField != "" {
+			msg.setField(cfg.JSONPrefixField, parsed.Prefix)
+		}
+
+		// Use JSON time as entry time
+		if !parsed.Time.IsZero() {
+			setSourceTime(cfg, &msg, parsed.Time)
+		}
+
+		// Use JSON message as log body
+		if parsed.Message != "" {
+			line = []byte(parsed.Message)
+		}
+
+		// Detect priority from level field
+		if parsed.Level != "" {
+			if pri, ok := cfg.LevelToPriority(parsed.Level); ok {
+				priority = pri
+				decision = priorityDecision{levelSource, parsed.LevelKey}
+				priorityDetected = true
+			}
+		}
+	} else if entry, ok := ParseAccessLog(cfg, line); ok {
+		// Access log line, kept as the message
+		for _, f := range entry.Fields {
+			msg.setField(f.Name, f.Value)
+		}
+		if !entry.Time.IsZero() {
+			setSourceTime(cfg, &msg, entry.Time)
+		}
+		if pri, rule, ok := cfg.statusPriority(entry.Status); ok {
+			priority = pri
+			decision = priorityDecision{prioritySourceStatus, rule}
+			priorityDetected = true
+		}
+	}
+
+	// Strip timestamp (before priority detection so ^ERROR matches after stripping)
+	if cfg.StripTimestamp {
+		var ts []byte
+		line, ts, _ = p.timestamps.Strip(line)
+		if cfg.TimestampFromApp && ts != nil && msg.SourceTime == 0 {
+			if t, ok := parseTimestamp(ts, cfg.TimestampLocation, time.Unix(0, msg.TimeNano)); ok {
+				setSourceTime(cfg, &msg, t)
+			}
+		}
+	}
+
+	// Detect priority via regex/default if not already detected from JSON
+	if !priorityDetected {
+		priority, line, decision = detectPriority(cfg, line, msg.Source)
+	}
+	if cfg.PriorityExplain {
+		msg.setPriorityFields(decision)
+	}
+
+	// Render derived fields and the message template
+	if len(cfg.DerivedFields) > 0 || cfg.MessageTemplate != nil {
+		data := p.templateData(line, jsonFields)
+		for _, d := range cfg.DerivedFields {
+			if v, ok := executeFieldTemplate(d.Template, data); ok && v != "" {
+				msg.setField(d.FieldName, v)
+			}
+		}
+		if cfg.MessageTemplate != nil {
+			if v, ok := executeFieldTemplate(cfg.MessageTemplate, data); ok {
+				line = []byte(v)
+			}
+		}
+	}
+
+	// Keep the unprocessed line
+	if cfg.KeepRaw && (!cfg.KeepRawOnChange || !bytes.Equal(line, msg.raw())) {
+		raw := string(msg.raw())
+		if len(raw) > cfg.KeepRawMaxBytes {
+			raw = truncateValue(raw, cfg.KeepRawMaxBytes)
+		}
+		msg.setField("RAW_MESSAGE", raw)
+	}
+
+	return journalEntry{
+		Msg:        msg,
+		Priority:   priority,
+		Line:       line,
+		JSONFields: jsonFields,
+	}
+}
+
+// templateData returns the variables for message-template and derived-field
+// templates: the parsed JSON or logfmt fields by key (a list for repeated
+// fields), plus .Message (the processed message) and .Container (the tag
+// template variables). These two win over parsed keys of the same name.
+func (p *messageProcessor) templateData(line []byte, jsonFields Fields) map[string]interface{} {
+	data := make(map[string]interface{}, len(jsonFields)+2)
+	for k, v := range jsonFields {
+		if len(v) == 1 {
+			data[k] = v[0]
+		} else {
+			data[k] = v // Repeated values, use {{range}}
+		}
+	}
+	data["Message"] = string(line)
+	data["Container"] = p.container
+	return data
+}
+
+// executeFieldTemplate renders a compiled template. Returns false if it
+// fails, e.g. because a referenced field is missing.
+func executeFieldTemplate(t *template.Template, data map[string]interface{}) (string, bool) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// setSourceTime uses an application timestamp as the entry time, unless it
+// is further than timestamp-max-skew from Docker's receive time. The skew is
+// recorded in TIMESTAMP_SKEW_USEC either way.
+func setSourceTime(cfg *Config, msg *mergedMessage, t time.Time) {
+	skew := time.Unix(0, msg.TimeNano).Sub(t)
+	msg.setField("TIMESTAMP_SKEW_USEC", strconv.FormatInt(skew.Microseconds(), 10))
+	if cfg.TimestampMaxSkew > 0 && (skew > cfg.TimestampMaxSkew || skew < -cfg.TimestampMaxSkew) {
+		return // implausible, keep Docker time
+	}
+	msg.SourceTime = t.UnixNano()
+}
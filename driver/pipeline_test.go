@@ -0,0 +1,380 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageProcessorPriorityExplain(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       map[string]string
+		line       string
+		wantPri    Priority
+		wantSource string
+		wantRule   string
+	}{
+		{"prefix", nil, "<4>low disk", PriWarning, "prefix", "priority-prefix"},
+		{"regex", nil, "FATAL crash", PriErr, "regex", "priority-match-err"},
+		{"default", nil, "hello", PriErr, "default", "priority-default-stderr"},
+		{"json", map[string]string{"parse-json": "true"}, `{"severity":"warn","msg":"slow"}`, PriWarning, "json", "severity"},
+		{"json without level", map[string]string{"parse-json": "true"}, `{"msg":"WARN slow"}`, PriWarning, "regex", "priority-match-warning"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := map[string]string{"priority-explain": "true"}
+			for k, v := range tt.opts {
+				opts[k] = v
+			}
+			cfg := mustConfig(t, opts)
+
+			e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{Line: []byte(tt.line), Source: "stderr"})
+			if e.Priority != tt.wantPri {
+				t.Errorf("priority = %d, want %d", e.Priority, tt.wantPri)
+			}
+			if e.Msg.Fields["PRIORITY_SOURCE"] != tt.wantSource {
+				t.Errorf("PRIORITY_SOURCE = %q, want %q", e.Msg.Fields["PRIORITY_SOURCE"], tt.wantSource)
+			}
+			if e.Msg.Fields["PRIORITY_RULE"] != tt.wantRule {
+				t.Errorf("PRIORITY_RULE = %q, want %q", e.Msg.Fields["PRIORITY_RULE"], tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestMessageProcessorNoPriorityExplain(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{})
+
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{Line: []byte("ERROR boom"), Source: "stdout"})
+	if len(e.Msg.Fields) != 0 {
+		t.Errorf("expected no pipeline fields, got %v", e.Msg.Fields)
+	}
+}
+
+func TestMessageProcessorSourceTime(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"strip-timestamp":  "true",
+		"timestamp-source": "app",
+	})
+	docker := time.Date(2024, 1, 15, 10, 30, 50, 0, time.UTC)
+
+	// Within skew: application time is used
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:     []byte("2024-01-15T10:30:45.5Z ERROR boom"),
+		Source:   "stdout",
+		TimeNano: docker.UnixNano(),
+	})
+	want := time.Date(2024, 1, 15, 10, 30, 45, 500000000, time.UTC)
+	if e.Msg.SourceTime != want.UnixNano() {
+		t.Errorf("SourceTime = %v, want %v", time.Unix(0, e.Msg.SourceTime).UTC(), want)
+	}
+	if e.Msg.Fields["TIMESTAMP_SKEW_USEC"] != "4500000" {
+		t.Errorf("TIMESTAMP_SKEW_USEC = %q, want 4500000", e.Msg.Fields["TIMESTAMP_SKEW_USEC"])
+	}
+	if string(e.Line) != "ERROR boom" || e.Priority != PriErr {
+		t.Errorf("line = %q, priority = %d", string(e.Line), e.Priority)
+	}
+
+	// Beyond skew: Docker time is kept, skew still recorded
+	e = newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:     []byte("2023-01-15T10:30:45Z boom"),
+		Source:   "stdout",
+		TimeNano: docker.UnixNano(),
+	})
+	if e.Msg.SourceTime != 0 {
+		t.Errorf("SourceTime = %d, want 0 (skew guard)", e.Msg.SourceTime)
+	}
+	if e.Msg.Fields["TIMESTAMP_SKEW_USEC"] == "" {
+		t.Error("TIMESTAMP_SKEW_USEC should be recorded when falling back")
+	}
+
+	// Without timestamp-source=app nothing is parsed
+	cfg = mustConfig(t, map[string]string{"strip-timestamp": "true"})
+	e = newMessageProcessor(cfg, tagData{}).Process(mergedMessage{Line: []byte("2024-01-15T10:30:45Z boom"), TimeNano: docker.UnixNano()})
+	if e.Msg.SourceTime != 0 || e.Msg.Fields != nil {
+		t.Errorf("unexpected source time %d / fields %v", e.Msg.SourceTime, e.Msg.Fields)
+	}
+}
+
+func TestMessageProcessorJSONTime(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":       "true",
+		"json-time-keys":   "time",
+		"strip-timestamp":  "true",
+		"timestamp-source": "app",
+	})
+	docker := time.Date(2024, 1, 15, 10, 30, 50, 0, time.UTC)
+
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:     []byte(`{"time":"2024-01-15T10:30:40Z","msg":"2024-01-15T10:30:45Z started"}`),
+		Source:   "stdout",
+		TimeNano: docker.UnixNano(),
+	})
+
+	// JSON time wins over the stripped message timestamp
+	want := time.Date(2024, 1, 15, 10, 30, 40, 0, time.UTC)
+	if e.Msg.SourceTime != want.UnixNano() {
+		t.Errorf("SourceTime = %v, want %v", time.Unix(0, e.Msg.SourceTime).UTC(), want)
+	}
+	if e.Msg.Fields["TIMESTAMP_SKEW_USEC"] != "10000000" {
+		t.Errorf("TIMESTAMP_SKEW_USEC = %q, want 10000000", e.Msg.Fields["TIMESTAMP_SKEW_USEC"])
+	}
+	if string(e.Line) != "started" {
+		t.Errorf("line = %q, want %q", string(e.Line), "started")
+	}
+	if _, ok := e.JSONFields["time"]; ok {
+		t.Error("time key should not be in JSON fields")
+	}
+}
+
+func TestMessageProcessorNumericLevel(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":   "true",
+		"level-scheme": "pino",
+	})
+
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:   []byte(`{"level":50,"msg":"request failed","pid":42}`),
+		Source: "stdout",
+	})
+	if e.Priority != PriErr {
+		t.Errorf("priority = %d, want %d", e.Priority, PriErr)
+	}
+	if _, ok := e.JSONFields["level"]; ok {
+		t.Error("level should not be in JSON fields")
+	}
+
+	// Without a level-scheme, numeric levels stay JSON fields
+	cfg = mustConfig(t, map[string]string{"parse-json": "true"})
+	e = newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:   []byte(`{"level":30,"msg":"x"}`),
+		Source: "stdout",
+	})
+	if e.Priority != PriInfo || e.JSONFields.Get("level") != "30" {
+		t.Errorf("priority = %d, level = %q; want %d, 30", e.Priority, e.JSONFields.Get("level"), PriInfo)
+	}
+}
+
+func TestMessageProcessorLogfmt(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":       "true",
+		"parse-logfmt":     "true",
+		"priority-explain": "true",
+	})
+	p := newMessageProcessor(cfg, tagData{})
+
+	e := p.Process(mergedMessage{
+		Line:   []byte(`level=warn msg="slow query" duration=2.5s`),
+		Source: "stdout",
+	})
+	if string(e.Line) != "slow query" {
+		t.Errorf("line = %q, want %q", e.Line, "slow query")
+	}
+	if e.Priority != PriWarning {
+		t.Errorf("priority = %d, want %d", e.Priority, PriWarning)
+	}
+	if e.JSONFields.Get("duration") != "2.5s" {
+		t.Errorf("duration field = %q, want %q", e.JSONFields["duration"], "2.5s")
+	}
+	if got := e.Msg.Fields["PRIORITY_SOURCE"]; got != "logfmt" {
+		t.Errorf("PRIORITY_SOURCE = %q, want %q", got, "logfmt")
+	}
+
+	// JSON still wins when the line is JSON
+	e = p.Process(mergedMessage{Line: []byte(`{"level":"error","msg":"x"}`), Source: "stdout"})
+	if got := e.Msg.Fields["PRIORITY_SOURCE"]; got != "json" {
+		t.Errorf("PRIORITY_SOURCE = %q, want %q", got, "json")
+	}
+
+	// Plain text falls back to the raw line
+	e = p.Process(mergedMessage{Line: []byte(`server started`), Source: "stdout"})
+	if string(e.Line) != "server started" || e.JSONFields != nil {
+		t.Errorf("plain text entry = %q %v, want raw line", e.Line, e.JSONFields)
+	}
+}
+
+func TestMessageProcessorJSONPrefixField(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":        "true",
+		"json-prefix-regex": `^\S+ \S+: `,
+		"json-prefix-field": "LOG_PREFIX",
+	})
+
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:   []byte(`2024-01-15T10:30:45Z app[web.1]: {"level":"error","msg":"boom"}`),
+		Source: "stdout",
+	})
+	if string(e.Line) != "boom" {
+		t.Errorf("line = %q, want %q", e.Line, "boom")
+	}
+	if e.Priority != PriErr {
+		t.Errorf("priority = %d, want %d", e.Priority, PriErr)
+	}
+	if got := e.Msg.Fields["LOG_PREFIX"]; got != "2024-01-15T10:30:45Z app[web.1]:" {
+		t.Errorf("LOG_PREFIX = %q", got)
+	}
+}
+
+func TestMessageProcessorTemplates(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-json":             "true",
+		"message-template":       "{{.method}} {{.path}} -> {{.status}} in {{.duration_ms}}ms",
+		"derived-field-ENDPOINT": "{{.method}} {{.path}}",
+		"derived-field-SERVICE":  "{{.Container.Name}}/{{.Message}}",
+	})
+	p := newMessageProcessor(cfg, tagData{Name: "api"})
+
+	e := p.Process(mergedMessage{
+		Line:   []byte(`{"level":"info","msg":"request","method":"GET","path":"/users","status":200,"duration_ms":12.5}`),
+		Source: "stdout",
+	})
+	if want := "GET /users -> 200 in 12.5ms"; string(e.Line) != want {
+		t.Errorf("line = %q, want %q", e.Line, want)
+	}
+	if got := e.Msg.Fields["ENDPOINT"]; got != "GET /users" {
+		t.Errorf("ENDPOINT = %q, want %q", got, "GET /users")
+	}
+	if got := e.Msg.Fields["SERVICE"]; got != "api/request" {
+		t.Errorf("SERVICE = %q, want %q", got, "api/request")
+	}
+
+	// Missing fields keep the message and skip derived fields
+	e = p.Process(mergedMessage{
+		Line:   []byte(`{"level":"info","msg":"started"}`),
+		Source: "stdout",
+	})
+	if string(e.Line) != "started" {
+		t.Errorf("line = %q, want %q", e.Line, "started")
+	}
+	if _, ok := e.Msg.Fields["ENDPOINT"]; ok {
+		t.Error("ENDPOINT should not be set when fields are missing")
+	}
+	if got := e.Msg.Fields["SERVICE"]; got != "api/started" {
+		t.Errorf("SERVICE = %q, want %q", got, "api/started")
+	}
+
+	// Built-in variables win over parsed keys of the same name
+	e = p.Process(mergedMessage{
+		Line:   []byte(`{"level":"info","msg":"started","Message":"other","Container":"other"}`),
+		Source: "stdout",
+	})
+	if got := e.Msg.Fields["SERVICE"]; got != "api/started" {
+		t.Errorf("SERVICE = %q, want %q", got, "api/started")
+	}
+
+	// Plain text lines keep their message
+	e = p.Process(mergedMessage{Line: []byte("plain line"), Source: "stdout"})
+	if string(e.Line) != "plain line" {
+		t.Errorf("line = %q, want %q", e.Line, "plain line")
+	}
+}
+
+func TestMessageProcessorKeepRaw(t *testing.T) {
+	jsonLine := `{"level":"error","msg":"boom"}`
+	tests := []struct {
+		name    string
+		opts    map[string]string
+		line    string
+		wantRaw string // "" = no RAW_MESSAGE
+	}{
+		{"disabled", map[string]string{"parse-json": "true"}, jsonLine, ""},
+		{"always, changed", map[string]string{"parse-json": "true", "keep-raw": "true"}, jsonLine, jsonLine},
+		{"always, unchanged", map[string]string{"keep-raw": "true"}, "plain", "plain"},
+		{"on-change, changed", map[string]string{"parse-json": "true", "keep-raw": "on-change"}, jsonLine, jsonLine},
+		{"on-change, prefix stripped", map[string]string{"keep-raw": "on-change"}, "<3>failed", "<3>failed"},
+		{"on-change, unchanged", map[string]string{"parse-json": "true", "keep-raw": "on-change"}, "plain", ""},
+		{
+			"size cap",
+			map[string]string{"keep-raw": "true", "keep-raw-max-bytes": "30"},
+			"0123456789012345678901234567890123456789",
+			"0123456789012345" + truncatedMarker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mustConfig(t, tt.opts)
+			e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{Line: []byte(tt.line), Source: "stdout"})
+			got, ok := e.Msg.Fields["RAW_MESSAGE"]
+			if tt.wantRaw == "" {
+				if ok {
+					t.Errorf("RAW_MESSAGE = %q, want none", got)
+				}
+				return
+			}
+			if got != tt.wantRaw {
+				t.Errorf("RAW_MESSAGE = %q, want %q", got, tt.wantRaw)
+			}
+		})
+	}
+
+	// Elements of a split array keep the whole array, as written
+	cfg := mustConfig(t, map[string]string{"parse-json": "true", "json-split-arrays": "true", "keep-raw": "on-change"})
+	array := `[{"msg":"a"},{"msg":"b"}]`
+	batch, _ := SplitJSONArray(cfg, mergedMessage{Line: []byte(array), Source: "stdout"})
+	for i, m := range batch {
+		e := newMessageProcessor(cfg, tagData{}).Process(m)
+		if got := e.Msg.Fields["RAW_MESSAGE"]; got != array {
+			t.Errorf("element %d RAW_MESSAGE = %q, want %q", i, got, array)
+		}
+	}
+}
+
+func TestMessageProcessorXML(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-xml": "log4j", "priority-explain": "true"})
+
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+		Line:     []byte(log4jEvent),
+		Source:   "stdout",
+		TimeNano: time.UnixMilli(1705314645123).Add(time.Second).UnixNano(),
+	})
+	if string(e.Line) != "Order 42 failed" {
+		t.Errorf("line = %q, want %q", e.Line, "Order 42 failed")
+	}
+	if e.Priority != PriErr {
+		t.Errorf("priority = %d, want %d", e.Priority, PriErr)
+	}
+	if e.JSONFields.Get("logger") != "com.example.OrderService" {
+		t.Errorf("logger = %q", e.JSONFields.Get("logger"))
+	}
+	if e.Msg.SourceTime != time.UnixMilli(1705314645123).UnixNano() {
+		t.Errorf("SourceTime = %d, want event timestamp", e.Msg.SourceTime)
+	}
+	if got := e.Msg.Fields["PRIORITY_SOURCE"]; got != "xml" {
+		t.Errorf("PRIORITY_SOURCE = %q, want %q", got, "xml")
+	}
+}
+
+func TestMessageProcessorAccessLog(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-accesslog": "combined", "priority-explain": "true"})
+	p := newMessageProcessor(cfg, tagData{})
+
+	line := `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "GET /cart HTTP/1.1" 503 0 "-" "curl/8.0"`
+	e := p.Process(mergedMessage{Line: []byte(line), Source: "stdout", TimeNano: time.Date(2024, 1, 15, 10, 30, 46, 0, time.UTC).UnixNano()})
+	if string(e.Line) != line {
+		t.Errorf("line = %q, want unchanged", e.Line)
+	}
+	if e.Priority != PriErr {
+		t.Errorf("priority = %d, want %d", e.Priority, PriErr)
+	}
+	if e.Msg.Fields["HTTP_PATH"] != "/cart" || e.Msg.Fields["HTTP_STATUS"] != "503" {
+		t.Errorf("fields = %v", e.Msg.Fields)
+	}
+	if e.Msg.Fields["PRIORITY_SOURCE"] != "status" || e.Msg.Fields["PRIORITY_RULE"] != "5xx" {
+		t.Errorf("PRIORITY_SOURCE = %q, PRIORITY_RULE = %q", e.Msg.Fields["PRIORITY_SOURCE"], e.Msg.Fields["PRIORITY_RULE"])
+	}
+	if e.Msg.SourceTime != time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC).UnixNano() {
+		t.Errorf("SourceTime = %d, want request time", e.Msg.SourceTime)
+	}
+
+	// Other lines on the same stream pass through untouched
+	errLine := `2024/01/15 10:30:45 [error] 29#29: *1 connect() failed`
+	e = p.Process(mergedMessage{Line: []byte(errLine), Source: "stderr", TimeNano: 1000})
+	if string(e.Line) != errLine || e.Priority != cfg.PriorityDefaultStderr {
+		t.Errorf("line = %q, priority = %d", e.Line, e.Priority)
+	}
+	if _, ok := e.Msg.Fields["HTTP_STATUS"]; ok {
+		t.Error("HTTP_STATUS should be absent for non-access log lines")
+	}
+}
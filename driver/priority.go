@@ -4,31 +4,62 @@ import "regexp"
 
 var sdDaemonPrefix = regexp.MustCompile(`^<([0-7])>`)
 
+// Priority sources recorded in the PRIORITY_SOURCE field.
+const (
+	prioritySourcePrefix  = "prefix"
+	prioritySourceJSON    = "json"
+	prioritySourceLogfmt  = "logfmt"
+	prioritySourceXML     = "xml"
+	prioritySourceStatus  = "status"
+	prioritySourceRegex   = "regex"
+	prioritySourceDefault = "default"
+)
+
+// priorityDecision records how a message's priority was determined.
+type priorityDecision struct {
+	Source string // prefix, json, logfmt, xml, status, regex or default
+	Rule   string // option name, JSON key or HTTP status that matched
+}
+
 // DetectPriority determines the journal priority for a message and returns
 // the (possibly stripped) message. It checks in order:
 // 1. sd-daemon <N> prefix (if enabled)
 // 2. priority-match-* regex patterns (first match wins)
 // 3. default based on source (stdout/stderr)
 func DetectPriority(cfg *Config, firstLine []byte, source string) (Priority, []byte) {
+	pri, line, _ := detectPriority(cfg, firstLine, source)
+	return pri, line
+}
+
+// detectPriority is DetectPriority, but also reports which rule decided.
+func detectPriority(cfg *Config, firstLine []byte, source string) (Priority, []byte, priorityDecision) {
 	// 1. sd-daemon prefix
 	if cfg.PriorityPrefix {
 		if loc := sdDaemonPrefix.FindSubmatchIndex(firstLine); loc != nil {
 			n := firstLine[loc[2]] - '0'
 			stripped := firstLine[loc[1]:]
-			return Priority(n), stripped
+			return Priority(n), stripped, priorityDecision{prioritySourcePrefix, "priority-prefix"}
 		}
 	}
 
 	// 2. Regex pattern matching
 	for _, m := range cfg.PriorityMatchers {
 		if m.Regex.Match(firstLine) {
-			return m.Priority, firstLine
+			return m.Priority, firstLine, priorityDecision{prioritySourceRegex, m.Option}
 		}
 	}
 
 	// 3. Default based on source
 	if source == "stderr" {
-		return cfg.PriorityDefaultStderr, firstLine
+		return cfg.PriorityDefaultStderr, firstLine, priorityDecision{prioritySourceDefault, "priority-default-stderr"}
+	}
+	return cfg.PriorityDefaultStdout, firstLine, priorityDecision{prioritySourceDefault, "priority-default-stdout"}
+}
+
+// setPriorityFields adds the PRIORITY_SOURCE and PRIORITY_RULE fields.
+func (m *mergedMessage) setPriorityFields(d priorityDecision) {
+	m.setField("PRIORITY_SOURCE", d.Source)
+	if d.Rule != "" {
+		m.setField("PRIORITY_RULE", d.Rule)
 	}
-	return cfg.PriorityDefaultStdout, firstLine
 }
@@ -165,3 +165,27 @@ func TestDetectPriorityPrefixTooLong(t *testing.T) {
 		t.Errorf("priority = %d, want %d (should fall through to default)", pri, PriInfo)
 	}
 }
+
+func TestDetectPriorityDecision(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{})
+
+	tests := []struct {
+		line       string
+		source     string
+		wantSource string
+		wantRule   string
+	}{
+		{"<3>Error occurred", "stdout", "prefix", "priority-prefix"},
+		{"ERROR something broke", "stdout", "regex", "priority-match-err"},
+		{"[Note] schema updated", "stdout", "regex", "priority-match-notice"},
+		{"plain message", "stdout", "default", "priority-default-stdout"},
+		{"plain message", "stderr", "default", "priority-default-stderr"},
+	}
+
+	for _, tt := range tests {
+		_, _, d := detectPriority(cfg, []byte(tt.line), tt.source)
+		if d.Source != tt.wantSource || d.Rule != tt.wantRule {
+			t.Errorf("line %q (%s): decision = %+v, want {%s %s}", tt.line, tt.source, d, tt.wantSource, tt.wantRule)
+		}
+	}
+}
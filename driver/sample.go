@@ -0,0 +1,90 @@
+package driver
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strconv"
+)
+
+// sampler drops a fraction of low-severity entries according to the
+// configured per-priority sample rates.
+type sampler struct {
+	cfg    *Config
+	random func() float64 // injectable for testing
+}
+
+func newSampler(cfg *Config) *sampler {
+	return &sampler{
+		cfg:    cfg,
+		random: rand.Float64,
+	}
+}
+
+// Keep reports whether an entry should be written. Kept entries with a
+// sample rate below 1 get a SAMPLE_RATE field, so counts can be scaled back up.
+func (s *sampler) Keep(e *journalEntry) bool {
+	rate, ok := s.cfg.SampleRates[e.Priority]
+	if !ok || rate >= 1 {
+		return true
+	}
+
+	var v float64
+	if key, ok := s.sampleKey(e); ok {
+		v = hashFraction(key)
+	} else {
+		v = s.random()
+	}
+	if v >= rate {
+		return false
+	}
+
+	e.Msg.setField("SAMPLE_RATE", strconv.FormatFloat(rate, 'g', -1, 64))
+	return true
+}
+
+// sampleKey returns the value used for consistent sampling. The key is
+// looked up among the JSON fields first, then among the field regexes and
+// field extractors. Returns false in random mode or if the entry has no key value.
+func (s *sampler) sampleKey(e *journalEntry) (string, bool) {
+	if s.cfg.SampleKey == "" {
+		return "", false
+	}
+	if v := e.JSONFields.Get(s.cfg.SampleKey); v != "" {
+		return v, true
+	}
+	for _, r := range s.cfg.FieldRegexes {
+		if i := r.SubexpIndex(s.cfg.SampleKey); i > 0 {
+			if m := r.FindSubmatch(e.Line); len(m) > i && len(m[i]) > 0 {
+				return string(m[i]), true
+			}
+		}
+	}
+	src := fieldSource{Message: string(e.Line), Raw: string(e.Msg.Line), JSON: e.JSONFields}
+	for i := range s.cfg.FieldExtractors {
+		extractor := &s.cfg.FieldExtractors[i]
+		if extractor.FieldName != s.cfg.SampleKey {
+			continue
+		}
+		for _, input := range extractor.inputs(&src) {
+			if m := extractor.Regex.FindStringSubmatch(input); len(m) > 1 && m[1] != "" {
+				return m[1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// hashFraction maps a key to a stable value in [0, 1).
+func hashFraction(key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// FNV's high bits vary little for short keys, so mix them first
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return float64(x>>11) / float64(math.MaxUint64>>11+1)
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSamplerKeepsUnsampledPriorities(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"sample-debug": "0"})
+	s := newSampler(cfg)
+
+	e := testEntry("disk full", "stderr", PriErr, 1)
+	if !s.Keep(&e) {
+		t.Error("err entries should always be kept")
+	}
+	if _, ok := e.Msg.Fields["SAMPLE_RATE"]; ok {
+		t.Error("unsampled entry should not carry SAMPLE_RATE")
+	}
+
+	e = testEntry("tracing", "stdout", PriDebug, 1)
+	if s.Keep(&e) {
+		t.Error("debug entries should be dropped with rate 0")
+	}
+}
+
+func TestSamplerRandom(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"sample-info": "0.25"})
+	s := newSampler(cfg)
+
+	tests := []struct {
+		random float64
+		want   bool
+	}{
+		{0.0, true},
+		{0.24, true},
+		{0.25, false},
+		{0.9, false},
+	}
+	for _, tt := range tests {
+		s.random = func() float64 { return tt.random }
+		e := testEntry("request handled", "stdout", PriInfo, 1)
+		if got := s.Keep(&e); got != tt.want {
+			t.Errorf("random=%v: Keep = %v, want %v", tt.random, got, tt.want)
+		}
+		if tt.want && e.Msg.Fields["SAMPLE_RATE"] != "0.25" {
+			t.Errorf("random=%v: SAMPLE_RATE = %q, want 0.25", tt.random, e.Msg.Fields["SAMPLE_RATE"])
+		}
+	}
+}
+
+func TestSamplerConsistent(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"sample-info":         "0.5",
+		"sample-mode":         "consistent",
+		"sample-key":          "TRACE_ID",
+		"field-TRACE_ID":      `trace=([a-z0-9]+)`,
+		"priority-match-info": "",
+	})
+	s := newSampler(cfg)
+	s.random = func() float64 {
+		t.Fatal("consistent mode should not use random numbers when a key is present")
+		return 0
+	}
+
+	// All lines of a request must share the same decision
+	kept := 0
+	for i := 0; i < 100; i++ {
+		trace := fmt.Sprintf("t%d", i)
+		first := testEntry("start trace="+trace, "stdout", PriInfo, 1)
+		decision := s.Keep(&first)
+		for j := 0; j < 3; j++ {
+			e := testEntry(fmt.Sprintf("step %d trace=%s", j, trace), "stdout", PriInfo, 1)
+			if s.Keep(&e) != decision {
+				t.Fatalf("trace %s: inconsistent sampling decision", trace)
+			}
+		}
+		if decision {
+			kept++
+		}
+	}
+	if kept == 0 || kept == 100 {
+		t.Errorf("kept %d of 100 traces, want a fraction", kept)
+	}
+
+	// JSON fields are also usable as keys
+	a := testEntry("a", "stdout", PriInfo, 1)
+	a.JSONFields = Fields{"TRACE_ID": {"abc"}}
+	b := testEntry("b", "stdout", PriInfo, 1)
+	b.JSONFields = Fields{"TRACE_ID": {"abc"}}
+	if s.Keep(&a) != s.Keep(&b) {
+		t.Error("same JSON key should give same decision")
+	}
+}
+
+func TestSamplerConsistentFallsBackToRandom(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"sample-debug": "0.5",
+		"sample-mode":  "consistent",
+		"sample-key":   "request_id",
+	})
+	s := newSampler(cfg)
+	s.random = func() float64 { return 0.9 }
+
+	e := testEntry("no key here", "stdout", PriDebug, 1)
+	if s.Keep(&e) {
+		t.Error("entry without key should use random sampling")
+	}
+}
+
+func TestSamplerKeyFromFieldRegex(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"sample-debug":  "0.5",
+		"sample-mode":   "consistent",
+		"sample-key":    "TRACE",
+		"field-regex-1": `trace=(?P<TRACE>\w+) span=(?P<SPAN>\w+)`,
+	})
+	s := newSampler(cfg)
+	e := &journalEntry{Priority: PriDebug, Line: []byte("x trace=abc span=def")}
+	key, ok := s.sampleKey(e)
+	if !ok || key != "abc" {
+		t.Errorf("sampleKey() = %q, %v; want %q, true", key, ok, "abc")
+	}
+}
@@ -0,0 +1,90 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// jsonSchemas are the json-schema presets, expressed as log-opt defaults.
+// Options set explicitly take precedence, except json-field-map entries,
+// which are added to the preset map.
+var jsonSchemas = map[string]map[string]string{
+	// Elastic Common Schema
+	"ecs": {
+		"parse-json":        "true",
+		"json-level-keys":   "log.level",
+		"json-message-keys": "message",
+		"json-time-keys":    "@timestamp",
+		"json-flatten":      "true",
+		"json-field-map": "trace.id:TRACE_ID,span.id:SPAN_ID,transaction.id:TRANSACTION_ID," +
+			"error.message:ERROR_MESSAGE,error.type:ERROR_TYPE,error.stack_trace:STACK_TRACE",
+		"json-fields-exclude": "ecs.version",
+	},
+
+	// OpenTelemetry log data model
+	"otel": {
+		"parse-json":        "true",
+		"json-level-keys":   "SeverityText,SeverityNumber",
+		"json-message-keys": "Body",
+		"json-time-keys":    "Timestamp,ObservedTimestamp",
+		"level-scheme":      "otel",
+		"json-flatten":      "true",
+		"json-field-map": "TraceId:TRACE_ID,SpanId:SPAN_ID,TraceFlags:TRACE_FLAGS," +
+			"Attributes.exception.message:ERROR_MESSAGE,Attributes.exception.type:ERROR_TYPE," +
+			"Attributes.exception.stacktrace:STACK_TRACE",
+	},
+
+	// Graylog Extended Log Format
+	"gelf": {
+		"parse-json":          "true",
+		"json-level-keys":     "level",
+		"json-message-keys":   "short_message",
+		"json-time-keys":      "timestamp",
+		"level-scheme":        "syslog",
+		"json-field-map":      "full_message:STACK_TRACE,_trace_id:TRACE_ID,_span_id:SPAN_ID",
+		"json-fields-exclude": "version",
+	},
+
+	// Bunyan (node-bunyan and compatible loggers)
+	"bunyan": {
+		"parse-json":          "true",
+		"json-level-keys":     "level",
+		"json-message-keys":   "msg",
+		"json-time-keys":      "time",
+		"level-scheme":        "pino",
+		"json-flatten":        "true",
+		"json-field-map":      "err.message:ERROR_MESSAGE,err.name:ERROR_TYPE,err.stack:STACK_TRACE",
+		"json-fields-exclude": "v",
+	},
+}
+
+// applyJSONSchema returns opts with the defaults of the json-schema preset
+// filled in. The opts map itself is not modified.
+func applyJSONSchema(opts map[string]string) (map[string]string, error) {
+	name, ok := opts["json-schema"]
+	if !ok || name == "" {
+		return opts, nil
+	}
+	preset, ok := jsonSchemas[name]
+	if !ok {
+		names := make([]string, 0, len(jsonSchemas))
+		for n := range jsonSchemas {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("invalid json-schema %q: must be one of %s", name, strings.Join(names, ", "))
+	}
+
+	merged := make(map[string]string, len(opts)+len(preset))
+	for k, v := range preset {
+		merged[k] = v
+	}
+	for k, v := range opts {
+		if p := preset[k]; k == "json-field-map" && p != "" && v != "" {
+			v = p + "," + v // Later entries win
+		}
+		merged[k] = v
+	}
+	return merged, nil
+}
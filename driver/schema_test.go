@@ -0,0 +1,114 @@
+package driver
+
+import "testing"
+
+func TestJSONSchemaPresets(t *testing.T) {
+	tests := []struct {
+		schema      string
+		line        string
+		wantMessage string
+		wantPri     Priority
+		wantFields  map[string]string // journal field name -> value
+		wantTime    bool
+	}{
+		{
+			schema:      "ecs",
+			line:        `{"@timestamp":"2024-03-01T12:00:00.000Z","log.level":"error","message":"request failed","ecs.version":"1.6.0","trace":{"id":"abc"},"error":{"type":"IOError","stack_trace":"at x\nat y"}}`,
+			wantMessage: "request failed",
+			wantPri:     PriErr,
+			wantFields:  map[string]string{"TRACE_ID": "abc", "ERROR_TYPE": "IOError", "STACK_TRACE": "at x\nat y"},
+			wantTime:    true,
+		},
+		{
+			schema:      "otel",
+			line:        `{"Timestamp":"1709294400000000000","SeverityNumber":17,"Body":"db timeout","TraceId":"5b8efff798038103d269b633813fc60c","Attributes":{"exception.stacktrace":"boom"}}`,
+			wantMessage: "db timeout",
+			wantPri:     PriErr,
+			wantFields:  map[string]string{"TRACE_ID": "5b8efff798038103d269b633813fc60c", "STACK_TRACE": "boom"},
+			wantTime:    true,
+		},
+		{
+			schema:      "otel",
+			line:        `{"SeverityText":"WARN","SeverityNumber":13,"Body":"slow"}`,
+			wantMessage: "slow",
+			wantPri:     PriWarning,
+			wantFields:  map[string]string{"JSON_SEVERITYNUMBER": "13"},
+		},
+		{
+			schema:      "gelf",
+			line:        `{"version":"1.1","host":"web1","short_message":"crashed","full_message":"trace\nline 2","timestamp":1709294400.5,"level":2,"_trace_id":"t1"}`,
+			wantMessage: "crashed",
+			wantPri:     PriCrit,
+			wantFields:  map[string]string{"STACK_TRACE": "trace\nline 2", "TRACE_ID": "t1", "JSON_HOST": "web1"},
+			wantTime:    true,
+		},
+		{
+			schema:      "bunyan",
+			line:        `{"name":"api","hostname":"h","pid":1,"level":50,"msg":"oops","time":"2024-03-01T12:00:00.000Z","v":0,"err":{"message":"oops","name":"TypeError","stack":"TypeError: oops\n    at f"}}`,
+			wantMessage: "oops",
+			wantPri:     PriErr,
+			wantFields:  map[string]string{"STACK_TRACE": "TypeError: oops\n    at f", "ERROR_TYPE": "TypeError", "JSON_NAME": "api"},
+			wantTime:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			cfg := mustConfig(t, map[string]string{"json-schema": tt.schema})
+			e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{
+				Line:     []byte(tt.line),
+				Source:   "stdout",
+				TimeNano: 1709294400000000000,
+			})
+			if string(e.Line) != tt.wantMessage {
+				t.Errorf("line = %q, want %q", e.Line, tt.wantMessage)
+			}
+			if e.Priority != tt.wantPri {
+				t.Errorf("priority = %d, want %d", e.Priority, tt.wantPri)
+			}
+			fields := make(map[string]string)
+			for k, v := range e.JSONFields {
+				if name, ok := cfg.jsonFieldName(k); ok {
+					fields[name] = v[0]
+				}
+			}
+			for name, want := range tt.wantFields {
+				if got := fields[name]; got != want {
+					t.Errorf("%s = %q, want %q (fields: %v)", name, got, want, fields)
+				}
+			}
+			if (e.Msg.SourceTime != 0) != tt.wantTime {
+				t.Errorf("SourceTime = %d, want set = %v", e.Msg.SourceTime, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaOverrides(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"json-schema":       "bunyan",
+		"json-message-keys": "message",
+		"json-field-map":    "err.stack:ERROR_STACK,req_id:REQUEST_ID",
+	})
+	if len(cfg.JSONMessageKeys) != 1 || cfg.JSONMessageKeys[0] != "message" {
+		t.Errorf("JSONMessageKeys = %v, want [message]", cfg.JSONMessageKeys)
+	}
+	if cfg.LevelScheme != "pino" {
+		t.Errorf("LevelScheme = %q, want pino", cfg.LevelScheme)
+	}
+	want := map[string]string{
+		"err.stack":   "ERROR_STACK",
+		"err.message": "ERROR_MESSAGE",
+		"req_id":      "REQUEST_ID",
+	}
+	for k, v := range want {
+		if got := cfg.JSONFieldMap[k]; got != v {
+			t.Errorf("JSONFieldMap[%q] = %q, want %q", k, got, v)
+		}
+	}
+
+	cfg = mustConfig(t, map[string]string{"json-schema": "ecs", "parse-json": "false"})
+	if cfg.ParseJSON {
+		t.Error("explicit parse-json=false should override the preset")
+	}
+}
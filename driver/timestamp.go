@@ -1,13 +1,33 @@
 package driver
 
-import "regexp"
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timestampFormat is a named timestamp regex pattern.
+type timestampFormat struct {
+	Name    string
+	Pattern string
+}
+
+// timestampPattern is a compiled timestampFormat.
+type timestampPattern struct {
+	Name  string
+	Regex *regexp.Regexp
+}
 
 // defaultTimestampPatterns defines the built-in timestamp patterns to strip.
 // Each pattern matches a timestamp at the start of a log line.
 // Order matters: more specific patterns should come first.
-var defaultTimestampPatterns = []string{
+// These are the reference definitions for the regex-free scanners in
+// builtinTimestampScanners, which must match exactly the same text.
+var defaultTimestampPatterns = []timestampFormat{
 	// Apache error log: Wed Oct 15 19:41:46.123456 2019
-	`^[A-Z][a-z]{2} [A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}(\.\d{1,6})? \d{4}`,
+	{"apache-error", `^[A-Z][a-z]{2} [A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}(\.\d{1,6})? \d{4}`},
 
 	// ISO 8601 and common variants:
 	//   2024-01-15T10:30:45.123456789Z
@@ -17,33 +37,33 @@ var defaultTimestampPatterns = []string{
 	// Covers: Log4j2, Logback, Python, Ruby, MySQL 5.7+, PostgreSQL, Docker
 	// Note: timezone abbreviations limited to Z/UTC/GMT to avoid matching
 	// log level words like ERROR, WARN, INFO, DEBUG.
-	`^\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}([.,]\d{1,9})?(Z|[+-]\d{2}:?\d{2})?(\s+(UTC|GMT))?\]?`,
+	{"iso8601", `^\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}([.,]\d{1,9})?(Z|[+-]\d{2}:?\d{2})?(\s+(UTC|GMT))?\]?`},
 
 	// Go log / nginx error: 2024/01/15 10:30:45 or 2024/01/15 10:30:45.000000
-	`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d{1,6})?`,
+	{"golog", `^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d{1,6})?`},
 
 	// Apache/nginx CLF: 15/Oct/2024:10:30:45 +0200 (optionally bracketed)
-	`^\[?\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}\s*[+-]\d{4}\]?`,
+	{"clf", `^\[?\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}\s*[+-]\d{4}\]?`},
 
 	// Log4j DATE format: 14 Nov 2017 20:30:20,434
-	`^\d{1,2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2}([.,]\d{1,3})?`,
+	{"log4j", `^\d{1,2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2}([.,]\d{1,3})?`},
 
 	// Syslog: Jan 15 10:30:45 or Jan  5 10:30:45
-	`^[A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}`,
+	{"syslog", `^[A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}`},
 }
 
 // trailingSep matches common separators after a timestamp.
 var trailingSep = regexp.MustCompile(`^[\s:|\-]*`)
 
-// compileTimestampPatterns compiles a list of regex pattern strings.
-func compileTimestampPatterns(patterns []string) ([]*regexp.Regexp, error) {
-	compiled := make([]*regexp.Regexp, 0, len(patterns))
-	for _, p := range patterns {
-		r, err := regexp.Compile(p)
+// compileTimestampPatterns compiles a list of timestamp formats.
+func compileTimestampPatterns(formats []timestampFormat) ([]timestampPattern, error) {
+	compiled := make([]timestampPattern, 0, len(formats))
+	for _, f := range formats {
+		r, err := regexp.Compile(f.Pattern)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s %q: %w", f.Name, f.Pattern, err)
 		}
-		compiled = append(compiled, r)
+		compiled = append(compiled, timestampPattern{Name: f.Name, Regex: r})
 	}
 	return compiled, nil
 }
@@ -51,9 +71,16 @@ func compileTimestampPatterns(patterns []string) ([]*regexp.Regexp, error) {
 // StripTimestamp removes a leading timestamp from a log line using the
 // provided compiled patterns. Returns the stripped line, or the original
 // if no pattern matches.
-func StripTimestamp(line []byte, patterns []*regexp.Regexp) []byte {
-	for _, re := range patterns {
-		loc := re.FindIndex(line)
+func StripTimestamp(line []byte, patterns []timestampPattern) []byte {
+	rest, _, _ := stripTimestamp(line, patterns)
+	return rest
+}
+
+// stripTimestamp is StripTimestamp, but also returns the matched timestamp
+// text and the name of the matching format (nil and "" if none matched).
+func stripTimestamp(line []byte, patterns []timestampPattern) (rest []byte, ts []byte, format string) {
+	for _, p := range patterns {
+		loc := p.Regex.FindIndex(line)
 		if loc == nil {
 			continue
 		}
@@ -62,7 +89,63 @@ func StripTimestamp(line []byte, patterns []*regexp.Regexp) []byte {
 		if sepLoc := trailingSep.FindIndex(rest); sepLoc != nil && sepLoc[1] > 0 {
 			rest = rest[sepLoc[1]:]
 		}
-		return rest
+		return rest, line[loc[0]:loc[1]], p.Name
+	}
+	return line, nil, ""
+}
+
+// timestampLayouts are tried in order when parsing a stripped timestamp.
+// Input is normalized first: brackets removed, whitespace collapsed, comma
+// decimal separators replaced and the ISO 8601 date/time space turned into T.
+// Fractional seconds are accepted by time.Parse without being in the layout.
+var timestampLayouts = []struct {
+	layout  string
+	hasYear bool
+}{
+	{"2006-01-02T15:04:05Z07:00", true},
+	{"2006-01-02T15:04:05Z0700", true},
+	{"2006-01-02T15:04:05", true},
+	{"2006/01/02 15:04:05", true},
+	{"02/Jan/2006:15:04:05 -0700", true},
+	{"02/Jan/2006:15:04:05-0700", true},
+	{"2 Jan 2006 15:04:05", true},
+	{"Mon Jan 2 15:04:05 2006", true},
+	{"Jan 2 15:04:05", false},
+}
+
+// isoDateTimeSep matches the space between date and time in ISO 8601 variants.
+var isoDateTimeSep = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}) `)
+
+// parseTimestamp parses timestamp text matched by one of the built-in
+// patterns. Zone-less formats are interpreted in loc. Syslog timestamps have
+// no year, so the year is taken from ref (the Docker receive time), moving
+// back a year if that would put the timestamp far in the future.
+func parseTimestamp(ts []byte, loc *time.Location, ref time.Time) (time.Time, bool) {
+	s := string(bytes.TrimSpace(ts))
+	s = strings.TrimPrefix(s, "[")
+	s = strings.TrimSuffix(s, "]")
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, ",", ".")
+	if base, ok := strings.CutSuffix(s, " UTC"); ok {
+		s, loc = base, time.UTC
+	} else if base, ok := strings.CutSuffix(s, " GMT"); ok {
+		s, loc = base, time.UTC
+	}
+	s = isoDateTimeSep.ReplaceAllString(s, "${1}T")
+
+	for _, l := range timestampLayouts {
+		t, err := time.ParseInLocation(l.layout, s, loc)
+		if err != nil {
+			continue
+		}
+		if !l.hasYear {
+			refLocal := ref.In(loc)
+			t = time.Date(refLocal.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+			if t.After(refLocal.AddDate(0, 6, 0)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, true
 	}
-	return line
+	return time.Time{}, false
 }
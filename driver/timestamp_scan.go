@@ -0,0 +1,335 @@
+package driver
+
+import "fmt"
+
+// Leading byte classes used to dispatch to timestamp scanners.
+const (
+	leadDigit uint8 = 1 << iota
+	leadBracket
+	leadUpper
+)
+
+// timestampScanner is a hand-written, regex-free matcher for one of the
+// built-in timestamp formats. Scan returns the length of the timestamp at the
+// start of line, or 0 if there is none. Each scanner matches exactly what the
+// corresponding pattern in defaultTimestampPatterns matches.
+type timestampScanner struct {
+	Name string
+	Lead uint8 // leading byte classes the format can start with
+	Scan func(line []byte) int
+}
+
+// builtinTimestampScanners lists the scanners in defaultTimestampPatterns order.
+var builtinTimestampScanners = []timestampScanner{
+	{"apache-error", leadUpper, scanApacheError},
+	{"iso8601", leadDigit | leadBracket, scanISO8601},
+	{"golog", leadDigit, scanGoLog},
+	{"clf", leadDigit | leadBracket, scanCLF},
+	{"log4j", leadDigit, scanLog4j},
+	{"syslog", leadUpper, scanSyslog},
+}
+
+// selectTimestampScanners returns the built-in scanners with the given
+// names, in built-in order.
+func selectTimestampScanners(names []string) ([]timestampScanner, error) {
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+	var scanners []timestampScanner
+	for _, s := range builtinTimestampScanners {
+		if want[s.Name] {
+			scanners = append(scanners, s)
+			delete(want, s.Name)
+		}
+	}
+	for name := range want {
+		return nil, fmt.Errorf("unknown timestamp format %q (valid: apache-error, iso8601, golog, clf, log4j, syslog)", name)
+	}
+	return scanners, nil
+}
+
+// timestampStripper strips leading timestamps for a single container. User
+// patterns are tried first, in order. Built-in formats use the scanners,
+// starting with the one that matched last, since a container rarely changes
+// its timestamp format. Not safe for concurrent use.
+type timestampStripper struct {
+	patterns []timestampPattern
+	scanners []timestampScanner
+	last     int // index of last matching scanner, -1 if none
+}
+
+func newTimestampStripper(cfg *Config) *timestampStripper {
+	return &timestampStripper{
+		patterns: cfg.StripTimestampPatterns,
+		scanners: cfg.StripTimestampScanners,
+		last:     -1,
+	}
+}
+
+// Strip removes a leading timestamp and trailing separators from line.
+// Returns the stripped line, the timestamp text and the matching format
+// name, or the original line, nil and "" if nothing matched.
+func (s *timestampStripper) Strip(line []byte) (rest []byte, ts []byte, format string) {
+	if len(s.patterns) > 0 {
+		if rest, ts, format := stripTimestamp(line, s.patterns); ts != nil {
+			return rest, ts, format
+		}
+	}
+
+	lead := leadClass(line)
+	if lead == 0 {
+		return line, nil, ""
+	}
+	if s.last >= 0 {
+		sc := s.scanners[s.last]
+		if sc.Lead&lead != 0 {
+			if n := sc.Scan(line); n > 0 {
+				return skipTrailingSep(line[n:]), line[:n], sc.Name
+			}
+		}
+	}
+	for i, sc := range s.scanners {
+		if i == s.last || sc.Lead&lead == 0 {
+			continue
+		}
+		if n := sc.Scan(line); n > 0 {
+			s.last = i
+			return skipTrailingSep(line[n:]), line[:n], sc.Name
+		}
+	}
+	return line, nil, ""
+}
+
+func leadClass(line []byte) uint8 {
+	if len(line) == 0 {
+		return 0
+	}
+	switch c := line[0]; {
+	case isDigit(c):
+		return leadDigit
+	case c == '[':
+		return leadBracket
+	case isUpper(c):
+		return leadUpper
+	}
+	return 0
+}
+
+// skipTrailingSep is the regex-free equivalent of trailingSep.
+func skipTrailingSep(b []byte) []byte {
+	i := 0
+	for i < len(b) && (isSpace(b[i]) || b[i] == ':' || b[i] == '|' || b[i] == '-') {
+		i++
+	}
+	return b[i:]
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+
+// isSpace matches the RE2 \s class: [\t\n\f\r ].
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// digits reports whether b[i:i+n] are all digits.
+func digits(b []byte, i, n int) bool {
+	if i+n > len(b) {
+		return false
+	}
+	for _, c := range b[i : i+n] {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// digitRun returns the end of up to max digits starting at i.
+func digitRun(b []byte, i, max int) int {
+	end := i
+	for end < len(b) && end-i < max && isDigit(b[end]) {
+		end++
+	}
+	return end
+}
+
+// byteAt reports whether b[i] == c.
+func byteAt(b []byte, i int, c byte) bool {
+	return i < len(b) && b[i] == c
+}
+
+// monthName reports whether b[i:i+3] looks like a capitalized name: [A-Z][a-z]{2}.
+func monthName(b []byte, i int) bool {
+	return i+3 <= len(b) && isUpper(b[i]) && isLower(b[i+1]) && isLower(b[i+2])
+}
+
+// clock matches \d{2}:\d{2}:\d{2} at i, returning the end or -1.
+func clock(b []byte, i int) int {
+	if digits(b, i, 2) && byteAt(b, i+2, ':') && digits(b, i+3, 2) && byteAt(b, i+5, ':') && digits(b, i+6, 2) {
+		return i + 8
+	}
+	return -1
+}
+
+// fraction matches an optional ([sep]\d{1,max}) at i, returning the new end.
+func fraction(b []byte, i int, seps string, max int) int {
+	if i < len(b) && (b[i] == seps[0] || (len(seps) > 1 && b[i] == seps[1])) {
+		if end := digitRun(b, i+1, max); end > i+1 {
+			return end
+		}
+	}
+	return i
+}
+
+// dayAndClock matches \d{1,2} \d{2}:\d{2}:\d{2} at i, returning the end or -1.
+func dayAndClock(b []byte, i int) int {
+	end := digitRun(b, i, 2)
+	if end == i || !byteAt(b, end, ' ') {
+		return -1
+	}
+	return clock(b, end+1)
+}
+
+// spaces1or2 matches \s{1,2} at i, returning the end or -1.
+func spaces1or2(b []byte, i int) int {
+	if i >= len(b) || !isSpace(b[i]) {
+		return -1
+	}
+	if i+1 < len(b) && isSpace(b[i+1]) {
+		return i + 2
+	}
+	return i + 1
+}
+
+// ^[A-Z][a-z]{2} [A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}(\.\d{1,6})? \d{4}
+func scanApacheError(b []byte) int {
+	if !monthName(b, 0) || !byteAt(b, 3, ' ') || !monthName(b, 4) {
+		return 0
+	}
+	i := spaces1or2(b, 7)
+	if i < 0 {
+		return 0
+	}
+	if i = dayAndClock(b, i); i < 0 {
+		return 0
+	}
+	i = fraction(b, i, ".", 6)
+	if !byteAt(b, i, ' ') || !digits(b, i+1, 4) {
+		return 0
+	}
+	return i + 5
+}
+
+// ^\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}([.,]\d{1,9})?(Z|[+-]\d{2}:?\d{2})?(\s+(UTC|GMT))?\]?
+func scanISO8601(b []byte) int {
+	i := 0
+	if byteAt(b, 0, '[') {
+		i = 1
+	}
+	if !digits(b, i, 4) || !byteAt(b, i+4, '-') || !digits(b, i+5, 2) || !byteAt(b, i+7, '-') || !digits(b, i+8, 2) {
+		return 0
+	}
+	i += 10
+	if !byteAt(b, i, 'T') && !byteAt(b, i, ' ') {
+		return 0
+	}
+	if i = clock(b, i+1); i < 0 {
+		return 0
+	}
+	i = fraction(b, i, ".,", 9)
+
+	// Zone
+	if byteAt(b, i, 'Z') {
+		i++
+	} else if (byteAt(b, i, '+') || byteAt(b, i, '-')) && digits(b, i+1, 2) {
+		j := i + 3
+		if byteAt(b, j, ':') && digits(b, j+1, 2) {
+			i = j + 3
+		} else if digits(b, j, 2) {
+			i = j + 2
+		}
+	}
+
+	// Zone abbreviation
+	j := i
+	for j < len(b) && isSpace(b[j]) {
+		j++
+	}
+	if j > i && j+3 <= len(b) {
+		if abbr := string(b[j : j+3]); abbr == "UTC" || abbr == "GMT" {
+			i = j + 3
+		}
+	}
+
+	if byteAt(b, i, ']') {
+		i++
+	}
+	return i
+}
+
+// ^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d{1,6})?
+func scanGoLog(b []byte) int {
+	if !digits(b, 0, 4) || !byteAt(b, 4, '/') || !digits(b, 5, 2) || !byteAt(b, 7, '/') || !digits(b, 8, 2) || !byteAt(b, 10, ' ') {
+		return 0
+	}
+	i := clock(b, 11)
+	if i < 0 {
+		return 0
+	}
+	return fraction(b, i, ".", 6)
+}
+
+// ^\[?\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}\s*[+-]\d{4}\]?
+func scanCLF(b []byte) int {
+	i := 0
+	if byteAt(b, 0, '[') {
+		i = 1
+	}
+	if !digits(b, i, 2) || !byteAt(b, i+2, '/') || !monthName(b, i+3) || !byteAt(b, i+6, '/') || !digits(b, i+7, 4) || !byteAt(b, i+11, ':') {
+		return 0
+	}
+	if i = clock(b, i+12); i < 0 {
+		return 0
+	}
+	for i < len(b) && isSpace(b[i]) {
+		i++
+	}
+	if !(byteAt(b, i, '+') || byteAt(b, i, '-')) || !digits(b, i+1, 4) {
+		return 0
+	}
+	i += 5
+	if byteAt(b, i, ']') {
+		i++
+	}
+	return i
+}
+
+// ^\d{1,2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2}([.,]\d{1,3})?
+func scanLog4j(b []byte) int {
+	i := digitRun(b, 0, 2)
+	if i == 0 || !byteAt(b, i, ' ') || !monthName(b, i+1) || !byteAt(b, i+4, ' ') || !digits(b, i+5, 4) || !byteAt(b, i+9, ' ') {
+		return 0
+	}
+	if i = clock(b, i+10); i < 0 {
+		return 0
+	}
+	return fraction(b, i, ".,", 3)
+}
+
+// ^[A-Z][a-z]{2}\s{1,2}\d{1,2} \d{2}:\d{2}:\d{2}
+func scanSyslog(b []byte) int {
+	if !monthName(b, 0) {
+		return 0
+	}
+	i := spaces1or2(b, 3)
+	if i < 0 {
+		return 0
+	}
+	if i = dayAndClock(b, i); i < 0 {
+		return 0
+	}
+	return i
+}
@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// timestampFixtures covers every built-in format plus near misses.
+var timestampFixtures = []string{
+	"Wed Oct 15 19:41:46.123456 2019 [error] message",
+	"Wed Oct  5 19:41:46 2019 [error] message",
+	"Wed Oct 15 19:41:46.1234567 2019 too many fraction digits",
+	"Wed Oct 15 19:41:46. 2019 empty fraction",
+	"2024-01-15T10:30:45 ERROR something",
+	"2024-01-15T10:30:45.123 ERROR something",
+	"2024-01-15 10:30:45,123 ERROR something",
+	"2024-01-15T10:30:45.123456789Z ERROR something",
+	"2024-01-15T10:30:45.1234567891Z ten fraction digits",
+	"2024-01-15T10:30:45+02:00 ERROR something",
+	"2024-01-15T10:30:45+0200 ERROR something",
+	"2024-01-15T10:30:45+02:0 broken offset",
+	"2024-01-15T10:30:45+02 short offset",
+	"2024-01-15 10:30:45.123 UTC ERROR something",
+	"2024-01-15 10:30:45.123  GMT ERROR something",
+	"2024-01-15 10:30:45.123 CET ERROR something",
+	"2024-01-15 10:30:45 UT short abbreviation",
+	"[2024-01-15 10:30:45] ERROR something",
+	"[2024-01-15 10:30:45 no closing bracket",
+	"2024-01-15T10:30:45 - ERROR something",
+	"2024-01-15T10:30:45 | ERROR something",
+	"2024-01-15T10:30:45: ERROR something",
+	"2024-01-15T10:30:45",
+	"2024/01/15 10:30:45 message here",
+	"2024/01/15 10:30:45.123456 message here",
+	"2024/01/15 10:30:45.1234567 message here",
+	"15/Oct/2024:10:30:45 +0200 GET /index.html",
+	"[15/Oct/2024:10:30:45 +0200] GET /index.html",
+	"15/Oct/2024:10:30:45+0200 no space",
+	"15/Oct/2024:10:30:45 0200 no sign",
+	"14 Nov 2017 20:30:20,434 INFO message",
+	"4 Nov 2017 20:30:20.4 INFO message",
+	"114 Nov 2017 20:30:20 three day digits",
+	"Jan 15 10:30:45 myhost message",
+	"Jan  5 10:30:45 myhost message",
+	"Jan \t5 10:30:45 tab padded",
+	"Jan   5 10:30:45 three spaces",
+	"Dec 31 23:59:59 message",
+	"ERROR no timestamp here",
+	"just a plain message",
+	"[Warning] not a timestamp",
+	"12345 not a timestamp",
+	"<3>2024-01-15T10:30:45Z prefixed",
+	"",
+}
+
+// mutate returns a random variation of s to explore near misses.
+func mutate(r *rand.Rand, s string) string {
+	const alphabet = "0123456789 :-/.,+TZUGMC[]JanOctWed\t"
+	b := []byte(s)
+	for n := r.IntN(3) + 1; n > 0 && len(b) > 0; n-- {
+		i := r.IntN(len(b))
+		switch r.IntN(3) {
+		case 0:
+			b[i] = alphabet[r.IntN(len(alphabet))]
+		case 1:
+			b = append(b[:i], b[i+1:]...)
+		default:
+			b = append(b[:i], append([]byte{alphabet[r.IntN(len(alphabet))]}, b[i:]...)...)
+		}
+	}
+	return string(b)
+}
+
+func TestTimestampScannersMatchPatterns(t *testing.T) {
+	patterns := compileDefaults(t)
+	if len(patterns) != len(builtinTimestampScanners) {
+		t.Fatalf("%d patterns but %d scanners", len(patterns), len(builtinTimestampScanners))
+	}
+
+	lines := append([]string(nil), timestampFixtures...)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 20000; i++ {
+		lines = append(lines, mutate(r, timestampFixtures[r.IntN(len(timestampFixtures))]))
+	}
+
+	for i, sc := range builtinTimestampScanners {
+		p := patterns[i]
+		if p.Name != sc.Name {
+			t.Fatalf("scanner %d is %q, pattern is %q", i, sc.Name, p.Name)
+		}
+		for _, line := range lines {
+			want := 0
+			if loc := p.Regex.FindStringIndex(line); loc != nil {
+				want = loc[1]
+			}
+			if got := sc.Scan([]byte(line)); got != want {
+				t.Errorf("%s: Scan(%q) = %d, regex matched %d", sc.Name, line, got, want)
+			}
+			if want > 0 && sc.Lead&leadClass([]byte(line)) == 0 {
+				t.Errorf("%s: line %q matched but lead class not dispatched", sc.Name, line)
+			}
+		}
+	}
+}
+
+func TestTimestampStripperMatchesStripTimestamp(t *testing.T) {
+	patterns := compileDefaults(t)
+	cfg := mustConfig(t, map[string]string{"strip-timestamp": "true"})
+	s := newTimestampStripper(cfg)
+
+	r := rand.New(rand.NewPCG(3, 4))
+	for i := 0; i < 20000; i++ {
+		line := timestampFixtures[r.IntN(len(timestampFixtures))]
+		if r.IntN(2) == 0 {
+			line = mutate(r, line)
+		}
+		wantRest, wantTS, wantFormat := stripTimestamp([]byte(line), patterns)
+		gotRest, gotTS, gotFormat := s.Strip([]byte(line))
+		if string(gotRest) != string(wantRest) || string(gotTS) != string(wantTS) || gotFormat != wantFormat {
+			t.Errorf("Strip(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				line, gotRest, gotTS, gotFormat, wantRest, wantTS, wantFormat)
+		}
+	}
+}
+
+func TestTimestampStripperRemembersFormat(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"strip-timestamp": "true"})
+	s := newTimestampStripper(cfg)
+
+	s.Strip([]byte("Jan 15 10:30:45 myhost message"))
+	if s.last < 0 || s.scanners[s.last].Name != "syslog" {
+		t.Fatalf("last = %d, want syslog", s.last)
+	}
+
+	// A different format still matches, and becomes the new hint
+	rest, _, format := s.Strip([]byte("2024-01-15T10:30:45Z ERROR something"))
+	if string(rest) != "ERROR something" || format != "iso8601" {
+		t.Errorf("got %q (%s)", string(rest), format)
+	}
+	if s.scanners[s.last].Name != "iso8601" {
+		t.Errorf("last = %s, want iso8601", s.scanners[s.last].Name)
+	}
+
+	// Non-matching lines keep the hint
+	s.Strip([]byte("plain message"))
+	if s.scanners[s.last].Name != "iso8601" {
+		t.Errorf("last = %s after miss, want iso8601", s.scanners[s.last].Name)
+	}
+}
+
+var benchmarkLines = [][]byte{
+	[]byte("2024-01-15T10:30:45.123456789Z INFO request handled in 12ms"),
+	[]byte("2024-01-15 10:30:45,123 UTC WARN slow query"),
+	[]byte("Jan 15 10:30:45 myhost sshd[123]: accepted publickey"),
+	[]byte("[15/Oct/2024:10:30:45 +0200] GET /index.html"),
+	[]byte("plain message without timestamp"),
+}
+
+func BenchmarkStripTimestampRegex(b *testing.B) {
+	patterns, err := compileTimestampPatterns(defaultTimestampPatterns)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, line := range benchmarkLines {
+		b.Run(string(line[:12]), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				StripTimestamp(line, patterns)
+			}
+		})
+	}
+}
+
+func BenchmarkStripTimestampScanner(b *testing.B) {
+	cfg, err := ParseConfig(map[string]string{"strip-timestamp": "true"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, line := range benchmarkLines {
+		b.Run(string(line[:12]), func(b *testing.B) {
+			s := newTimestampStripper(cfg)
+			for i := 0; i < b.N; i++ {
+				s.Strip(line)
+			}
+		})
+	}
+}
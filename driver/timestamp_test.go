@@ -1,11 +1,11 @@
 package driver
 
 import (
-	"regexp"
 	"testing"
+	"time"
 )
 
-func compileDefaults(t *testing.T) []*regexp.Regexp {
+func compileDefaults(t *testing.T) []timestampPattern {
 	t.Helper()
 	patterns, err := compileTimestampPatterns(defaultTimestampPatterns)
 	if err != nil {
@@ -158,7 +158,7 @@ func TestStripTimestampOnlyTimestamp(t *testing.T) {
 
 func TestStripTimestampCustomPattern(t *testing.T) {
 	// MySQL 5.6 short format: 230515 14:30:45
-	patterns, err := compileTimestampPatterns([]string{`^\d{6} \d{2}:\d{2}:\d{2}`})
+	patterns, err := compileTimestampPatterns([]timestampFormat{{"mysql", `^\d{6} \d{2}:\d{2}:\d{2}`}})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -168,3 +168,140 @@ func TestStripTimestampCustomPattern(t *testing.T) {
 		t.Errorf("got %q, want %q", string(got), "[Note] message")
 	}
 }
+
+func TestParseTimestampDefaultFormats(t *testing.T) {
+	patterns := compileDefaults(t)
+	ref := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"ISO Z", "2024-01-15T10:30:45.123Z msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 123000000, time.UTC)},
+		{"ISO nanos", "2024-01-15T10:30:45.123456789Z msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 123456789, time.UTC)},
+		{"ISO offset", "2024-01-15T10:30:45+02:00 msg", time.UTC, time.Date(2024, 1, 15, 8, 30, 45, 0, time.UTC)},
+		{"ISO compact offset", "2024-01-15T10:30:45+0200 msg", time.UTC, time.Date(2024, 1, 15, 8, 30, 45, 0, time.UTC)},
+		{"ISO comma UTC", "2024-01-15 10:30:45,123 UTC msg", berlin, time.Date(2024, 1, 15, 10, 30, 45, 123000000, time.UTC)},
+		{"ISO zone-less", "2024-01-15 10:30:45 msg", berlin, time.Date(2024, 1, 15, 9, 30, 45, 0, time.UTC)},
+		{"ISO bracketed", "[2024-01-15 10:30:45] msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)},
+		{"Go log", "2024/01/15 10:30:45.000123 msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 123000, time.UTC)},
+		{"CLF", "[15/Jan/2024:10:30:45 +0200] GET /", time.UTC, time.Date(2024, 1, 15, 8, 30, 45, 0, time.UTC)},
+		{"Log4j DATE", "15 Jan 2024 10:30:45,434 INFO msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 434000000, time.UTC)},
+		{"Apache error", "Mon Jan 15 10:30:45.123456 2024 [error] msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 123456000, time.UTC)},
+		{"Syslog", "Jan 15 10:30:45 host msg", time.UTC, time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)},
+		{"Syslog padded day", "Jan  5 10:30:45 host msg", time.UTC, time.Date(2024, 1, 5, 10, 30, 45, 0, time.UTC)},
+		{"Syslog previous year", "Dec 31 23:59:59 host msg", time.UTC, time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts, _ := stripTimestamp([]byte(tt.line), patterns)
+			if ts == nil {
+				t.Fatalf("no timestamp matched in %q", tt.line)
+			}
+			got, ok := parseTimestamp(ts, tt.loc, ref)
+			if !ok {
+				t.Fatalf("parseTimestamp(%q) failed", string(ts))
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTimestamp(%q) = %v, want %v", string(ts), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampUnparseable(t *testing.T) {
+	// MySQL 5.6 short format matched by a custom pattern
+	if _, ok := parseTimestamp([]byte("230515 14:30:45"), time.UTC, time.Now()); ok {
+		t.Error("expected unknown format to fail parsing")
+	}
+}
+
+func TestStripTimestampMatchedFormat(t *testing.T) {
+	patterns := compileDefaults(t)
+
+	tests := []struct {
+		line   string
+		format string
+	}{
+		{"Wed Oct 15 19:41:46.123456 2019 [error] message", "apache-error"},
+		{"2024-01-15T10:30:45.123Z ERROR something", "iso8601"},
+		{"2024-01-15 10:30:45,123 UTC ERROR something", "iso8601"},
+		{"[2024-01-15 10:30:45] ERROR something", "iso8601"},
+		{"2024/01/15 10:30:45.123456 message here", "golog"},
+		{"[15/Oct/2024:10:30:45 +0200] GET /index.html", "clf"},
+		{"14 Nov 2017 20:30:20,434 INFO message", "log4j"},
+		{"Jan  5 10:30:45 myhost message", "syslog"},
+		{"ERROR no timestamp here", ""},
+	}
+
+	for _, tt := range tests {
+		_, _, format := stripTimestamp([]byte(tt.line), patterns)
+		if format != tt.format {
+			t.Errorf("line %q: matched format %q, want %q", tt.line, format, tt.format)
+		}
+	}
+}
+
+func TestStripTimestampSelectedFormats(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"strip-timestamp":         "true",
+		"strip-timestamp-formats": "syslog, iso8601",
+	})
+
+	// Built-in order is kept regardless of listing order
+	var names []string
+	for _, s := range cfg.StripTimestampScanners {
+		names = append(names, s.Name)
+	}
+	if len(names) != 2 || names[0] != "iso8601" || names[1] != "syslog" {
+		t.Fatalf("patterns = %v, want [iso8601 syslog]", names)
+	}
+
+	tests := []struct {
+		line   string
+		want   string
+		format string
+	}{
+		{"2024-01-15T10:30:45Z ERROR something", "ERROR something", "iso8601"},
+		{"Jan 15 10:30:45 myhost message", "myhost message", "syslog"},
+		{"2024/01/15 10:30:45 not selected", "2024/01/15 10:30:45 not selected", ""},
+	}
+	for _, tt := range tests {
+		got, _, format := newTimestampStripper(cfg).Strip([]byte(tt.line))
+		if string(got) != tt.want || format != tt.format {
+			t.Errorf("line %q: got %q (%q), want %q (%q)", tt.line, string(got), format, tt.want, tt.format)
+		}
+	}
+}
+
+func TestStripTimestampExtraPatterns(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"strip-timestamp":               "true",
+		"strip-timestamp-extra-regex":   `^\d{6} \d{2}:\d{2}:\d{2}`,
+		"strip-timestamp-extra-regex-2": `^@\d+\.\d+`,
+	})
+
+	tests := []struct {
+		line   string
+		want   string
+		format string
+	}{
+		{"230515 14:30:45 [Note] message", "[Note] message", "strip-timestamp-extra-regex"},
+		{"@1705314645.123 started", "started", "strip-timestamp-extra-regex-2"},
+		{"2024-01-15T10:30:45Z ERROR something", "ERROR something", "iso8601"},
+		{"Jan 15 10:30:45 myhost message", "myhost message", "syslog"},
+	}
+	for _, tt := range tests {
+		got, _, format := newTimestampStripper(cfg).Strip([]byte(tt.line))
+		if string(got) != tt.want || format != tt.format {
+			t.Errorf("line %q: got %q (%q), want %q (%q)", tt.line, string(got), format, tt.want, tt.format)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fieldTransform converts a field value, see field-FIELDNAME-transform.
+type fieldTransform func(string) string
+
+// untransformedFields are the entry and bookkeeping fields that transforms
+// cannot be applied to.
+var untransformedFields = map[string]bool{
+	"MESSAGE":                   true,
+	"PRIORITY":                  true,
+	"SYSLOG_TIMESTAMP":          true,
+	"SOURCE_REALTIME_TIMESTAMP": true,
+	"TIMESTAMP_SKEW_USEC":       true,
+	"PRIORITY_SOURCE":           true,
+	"PRIORITY_RULE":             true,
+	"SAMPLE_RATE":               true,
+	"REPEAT_COUNT":              true,
+	"BATCH_ID":                  true,
+	"RAW_MESSAGE":               true,
+	"FIELDS_DROPPED":            true,
+	"FIELDS_TRUNCATED":          true,
+}
+
+// parseFieldTransforms parses a comma-separated transform chain. The hmac
+// key is the content of the hmac-key-file, nil if not set.
+func parseFieldTransforms(spec string, hmacKey []byte) ([]fieldTransform, error) {
+	var chain []fieldTransform
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "lower":
+			chain = append(chain, strings.ToLower)
+		case name == "upper":
+			chain = append(chain, strings.ToUpper)
+		case name == "trim":
+			chain = append(chain, strings.TrimSpace)
+		case strings.HasPrefix(name, "truncate:"):
+			n, err := strconv.Atoi(strings.TrimPrefix(name, "truncate:"))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid transform %q: length must be a positive integer", name)
+			}
+			chain = append(chain, func(v string) string { return truncateRunes(v, n) })
+		case name == "sha256":
+			chain = append(chain, func(v string) string {
+				sum := sha256.Sum256([]byte(v))
+				return hex.EncodeToString(sum[:])
+			})
+		case name == "hmac" || name == "hmac-sha256":
+			if hmacKey == nil {
+				return nil, fmt.Errorf("invalid transform %q: requires hmac-key-file", name)
+			}
+			chain = append(chain, func(v string) string {
+				mac := hmac.New(sha256.New, hmacKey)
+				mac.Write([]byte(v))
+				return hex.EncodeToString(mac.Sum(nil))
+			})
+		default:
+			return nil, fmt.Errorf("unknown transform %q: must be lower, upper, trim, truncate:N, sha256 or hmac-sha256", name)
+		}
+	}
+	return chain, nil
+}
+
+// readHMACKey reads the hmac-key-file. Surrounding whitespace, such as a
+// trailing newline, is not part of the key.
+func readHMACKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key file is empty")
+	}
+	return key, nil
+}
+
+// truncateRunes cuts v to at most n characters.
+func truncateRunes(v string, n int) string {
+	if utf8.RuneCountInString(v) <= n {
+		return v
+	}
+	i := 0
+	for pos := range v {
+		if i == n {
+			return v[:pos]
+		}
+		i++
+	}
+	return v
+}
+
+// transformFields applies the field-FIELDNAME-transform chains to vars. The
+// value slices may be shared, so transformed values are written to new ones.
+func (c *Config) transformFields(vars Fields) {
+	for name, chain := range c.FieldTransforms {
+		values, ok := vars[name]
+		if !ok {
+			continue
+		}
+		out := make([]string, len(values))
+		for i, v := range values {
+			for _, t := range chain {
+				v = t(v)
+			}
+			out[i] = v
+		}
+		vars[name] = out
+	}
+}
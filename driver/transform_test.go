@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFieldTransforms(t *testing.T) {
+	key := []byte("secret")
+	tests := []struct {
+		spec  string
+		input string
+		want  string
+	}{
+		{"lower", "Alice@Example.COM", "alice@example.com"},
+		{"upper", "abc", "ABC"},
+		{"trim", "  x \t", "x"},
+		{"truncate:3", "héllo", "hél"},
+		{"truncate:10", "short", "short"},
+		{"sha256", "alice", "2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90"},
+		{"hmac-sha256", "alice", "4360c67bc81025114044578d7c4e8e0f02fd0cae99f22d603390e8f9dc9888f8"},
+		{"trim, lower, sha256", " Alice ", "2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90"},
+		{"sha256,truncate:12", "alice", "2bd806c97f0e"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			chain, err := parseFieldTransforms(tt.spec, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tt.input
+			for _, f := range chain {
+				got = f(got)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalWriterFieldTransforms(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "hmac.key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := mustConfig(t, map[string]string{
+		"hmac-key-file":              keyFile,
+		"field-USER_ID":              `user=(\w+)`,
+		"field-USER_ID-transform":    "lower,hmac",
+		"field-JSON_EMAIL-transform": "lower,sha256",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{ContainerID: "abcdef123456789012345678"})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	jsonFields := Fields{"email": {"Alice@Example.com", "bob@example.com"}}
+	w.Write(mergedMessage{Line: []byte("x"), TimeNano: 1000}, PriInfo, []byte("login user=Alice"), jsonFields)
+
+	if got := lastVars.Get("USER_ID"); got != "4360c67bc81025114044578d7c4e8e0f02fd0cae99f22d603390e8f9dc9888f8" {
+		t.Errorf("USER_ID = %q, want hmac of alice", got)
+	}
+	emails := lastVars["JSON_EMAIL"]
+	if len(emails) != 2 || emails[0] != "ff8d9819fc0e12bf0d24892e45987e249a28dce836a85cad60e28eaaa8c6d976" {
+		t.Errorf("JSON_EMAIL = %q, want sha256 of each value", emails)
+	}
+	if jsonFields.Get("email") != "Alice@Example.com" {
+		t.Error("transform modified the parsed JSON fields")
+	}
+}
+
+func TestJournalWriterPipelineFieldTransforms(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{
+		"parse-accesslog":             "combined",
+		"field-HTTP_CLIENT-transform": "sha256",
+		"derived-field-ROUTE":         "{{.Message}}",
+		"field-ROUTE-transform":       "truncate:7,upper",
+		"dedupe-window":               "1s",
+	})
+	infoJSON, _ := json.Marshal(containerInfo{ContainerID: "abcdef123456789012345678"})
+
+	var lastVars Fields
+	sendFn := func(message string, priority Priority, vars Fields) error {
+		lastVars = vars
+		return nil
+	}
+	w, err := newJournalWriter(cfg, json.RawMessage(infoJSON), sendFn)
+	if err != nil {
+		t.Fatalf("newJournalWriter: %v", err)
+	}
+
+	line := `10.0.0.1 - - [15/Jan/2024:10:30:45 +0000] "GET / HTTP/1.1" 200 5 "-" "curl/8.0"`
+	e := newMessageProcessor(cfg, tagData{}).Process(mergedMessage{Line: []byte(line), Source: "stdout", TimeNano: 1000})
+	e.Msg.setField("REPEAT_COUNT", "3")
+	w.Write(e.Msg, e.Priority, e.Line, e.JSONFields)
+
+	// sha256 of 10.0.0.1
+	if got := lastVars.Get("HTTP_CLIENT"); got != "f5047344122f0dee9974ba6761e61c6b8649e1f3968d13a635ebbf7be53a3a0d" {
+		t.Errorf("HTTP_CLIENT = %q, want sha256 of the client", got)
+	}
+	if got := lastVars.Get("ROUTE"); got != "10.0.0." {
+		t.Errorf("ROUTE = %q, want %q", got, "10.0.0.")
+	}
+	if got := lastVars.Get("REPEAT_COUNT"); got != "3" {
+		t.Errorf("REPEAT_COUNT = %q, want 3", got)
+	}
+}
@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"strings"
+)
+
+// xmlLogFormat describes an XML event layout. Element names are matched on
+// their local part, since the layouts use undeclared namespace prefixes.
+type xmlLogFormat struct {
+	Name      string
+	Event     string         // event element
+	Message   string         // message element
+	Throwable string         // exception element
+	Start     *regexp.Regexp // start tag, begins a multiline record
+	End       *regexp.Regexp // end tag, completes a multiline record
+}
+
+// Built-in XML layouts, selected with the parse-xml option.
+var xmlLogFormats = map[string]*xmlLogFormat{
+	// log4j 1.x XMLLayout (also log4net XmlLayoutSchemaLog4j)
+	"log4j": {
+		Name:      "log4j",
+		Event:     "event",
+		Message:   "message",
+		Throwable: "throwable",
+		Start:     regexp.MustCompile(`<log4j:event[\s>]`),
+		End:       regexp.MustCompile(`</log4j:event>`),
+	},
+	// log4net XmlLayout, with the default "log4net" prefix or none
+	"log4net": {
+		Name:      "log4net",
+		Event:     "event",
+		Message:   "message",
+		Throwable: "exception",
+		Start:     regexp.MustCompile(`<(?:log4net:)?event[\s>]`),
+		End:       regexp.MustCompile(`</(?:log4net:)?event>`),
+	},
+}
+
+// ParseXMLLog attempts to parse a log line as an XML log event. The level
+// attribute gives the level, the timestamp attribute the entry time, and
+// the message element the message. The logger, thread and other event
+// attributes, the exception text, location info and properties become extra
+// fields, just like ParseJSONLog. Text before the event start tag is ignored.
+// Returns (nil, false) if the line holds no complete event or no message.
+func ParseXMLLog(cfg *Config, line []byte) (*JSONParsedLog, bool) {
+	f := cfg.XMLFormat
+	if f == nil || len(line) == 0 {
+		return nil, false
+	}
+	loc := f.Start.FindIndex(line)
+	if loc == nil {
+		return nil, false
+	}
+
+	result := &JSONParsedLog{
+		ExtraFields: make(Fields),
+	}
+	fields := result.ExtraFields
+	dec := xml.NewDecoder(bytes.NewReader(line[loc[0]:]))
+	depth := 0
+	var text strings.Builder
+	var textKey string // element whose text is being collected
+	hasMessage := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false // Incomplete or malformed event
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1:
+				if t.Name.Local != f.Event {
+					return nil, false
+				}
+				for _, a := range t.Attr {
+					switch a.Name.Local {
+					case "level":
+						result.Level = a.Value
+						result.LevelKey = "level"
+					case "timestamp":
+						if ts, ok := parseJSONTime(a.Value, cfg.TimestampLocation); ok {
+							result.Time = ts
+						}
+					default:
+						fields.Set(a.Name.Local, a.Value)
+					}
+				}
+			case depth == 2 && (t.Name.Local == f.Message || t.Name.Local == f.Throwable):
+				textKey = t.Name.Local
+				text.Reset()
+			case t.Name.Local == "locationInfo":
+				for _, a := range t.Attr {
+					fields.Set(a.Name.Local, a.Value)
+				}
+			case t.Name.Local == "data":
+				var name, value string
+				for _, a := range t.Attr {
+					switch a.Name.Local {
+					case "name":
+						name = a.Value
+					case "value":
+						value = a.Value
+					}
+				}
+				if name != "" {
+					fields.Add(name, value)
+				}
+			}
+		case xml.CharData:
+			if textKey != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 && textKey != "" {
+				if textKey == f.Message {
+					result.Message = text.String()
+					hasMessage = true
+				} else if s := strings.TrimRight(text.String(), "\r\n"); s != "" {
+					fields.Set(textKey, s)
+				}
+				textKey = ""
+			}
+			depth--
+			if depth == 0 {
+				if !hasMessage || result.Message == "" {
+					return nil, false
+				}
+				appendStringKeys(cfg, result, fields)
+				return result, true
+			}
+		}
+	}
+}
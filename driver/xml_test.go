@@ -0,0 +1,191 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const log4jEvent = `<log4j:event logger="com.example.OrderService" timestamp="1705314645123" level="ERROR" thread="http-nio-8080-exec-1">
+<log4j:message><![CDATA[Order 42 failed]]></log4j:message>
+<log4j:throwable><![CDATA[java.lang.IllegalStateException: boom
+	at com.example.OrderService.place(OrderService.java:17)
+]]></log4j:throwable>
+<log4j:locationInfo class="com.example.OrderService" method="place" file="OrderService.java" line="17"/>
+<log4j:properties><log4j:data name="requestId" value="r-1"/></log4j:properties>
+</log4j:event>`
+
+const log4netEvent = `<log4net:event logger="MyApp.Program" timestamp="2024-01-15T10:30:45.1230000+01:00" level="WARN" thread="1" domain="MyApp.exe" username="svc">` +
+	`<log4net:message>Disk &lt;90%&gt; full</log4net:message>` +
+	`<log4net:properties><log4net:data name="log4net:HostName" value="web1" /></log4net:properties>` +
+	`<log4net:exception>System.IO.IOException: disk</log4net:exception></log4net:event>`
+
+func TestParseXMLLog(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		line        string
+		wantOK      bool
+		wantLevel   string
+		wantMessage string
+		wantTime    time.Time
+		wantFields  map[string]string
+	}{
+		{
+			name:        "log4j",
+			format:      "log4j",
+			line:        log4jEvent,
+			wantOK:      true,
+			wantLevel:   "ERROR",
+			wantMessage: "Order 42 failed",
+			wantTime:    time.UnixMilli(1705314645123),
+			wantFields: map[string]string{
+				"logger":    "com.example.OrderService",
+				"thread":    "http-nio-8080-exec-1",
+				"throwable": "java.lang.IllegalStateException: boom\n\tat com.example.OrderService.place(OrderService.java:17)",
+				"class":     "com.example.OrderService",
+				"method":    "place",
+				"file":      "OrderService.java",
+				"line":      "17",
+				"requestId": "r-1",
+			},
+		},
+		{
+			name:        "log4net",
+			format:      "log4net",
+			line:        log4netEvent,
+			wantOK:      true,
+			wantLevel:   "WARN",
+			wantMessage: "Disk <90%> full",
+			wantTime:    time.Date(2024, 1, 15, 9, 30, 45, 123000000, time.UTC),
+			wantFields: map[string]string{
+				"logger":           "MyApp.Program",
+				"thread":           "1",
+				"domain":           "MyApp.exe",
+				"username":         "svc",
+				"log4net:HostName": "web1",
+				"exception":        "System.IO.IOException: disk",
+			},
+		},
+		{
+			name:        "log4net without prefix",
+			format:      "log4net",
+			line:        `<event logger="A" level="INFO"><message>hi</message></event>`,
+			wantOK:      true,
+			wantLevel:   "INFO",
+			wantMessage: "hi",
+			wantFields:  map[string]string{"logger": "A"},
+		},
+		{
+			name:        "text before event",
+			format:      "log4j",
+			line:        `2024-01-15 10:30:45 ` + `<log4j:event level="INFO"><log4j:message>hi</log4j:message></log4j:event>`,
+			wantOK:      true,
+			wantLevel:   "INFO",
+			wantMessage: "hi",
+			wantFields:  map[string]string{},
+		},
+		{
+			name:   "incomplete event",
+			format: "log4j",
+			line:   `<log4j:event level="INFO"><log4j:message>hi</log4j:message>`,
+			wantOK: false,
+		},
+		{
+			name:   "no message",
+			format: "log4j",
+			line:   `<log4j:event level="INFO"></log4j:event>`,
+			wantOK: false,
+		},
+		{
+			name:   "wrong layout",
+			format: "log4j",
+			line:   log4netEvent,
+			wantOK: false,
+		},
+		{
+			name:   "plain text",
+			format: "log4j",
+			line:   `ERROR something failed`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mustConfig(t, map[string]string{"parse-xml": tt.format})
+			parsed, ok := ParseXMLLog(cfg, []byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("ParseXMLLog() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if parsed.Level != tt.wantLevel {
+				t.Errorf("Level = %q, want %q", parsed.Level, tt.wantLevel)
+			}
+			if parsed.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", parsed.Message, tt.wantMessage)
+			}
+			if !parsed.Time.Equal(tt.wantTime) {
+				t.Errorf("Time = %v, want %v", parsed.Time, tt.wantTime)
+			}
+			if len(parsed.ExtraFields) != len(tt.wantFields) {
+				t.Errorf("ExtraFields = %v, want %v", parsed.ExtraFields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if got := parsed.ExtraFields.Get(k); got != v {
+					t.Errorf("ExtraFields[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseXMLLogAppendThrowable(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-xml": "log4j", "json-append-keys": "throwable"})
+	parsed, ok := ParseXMLLog(cfg, []byte(log4jEvent))
+	if !ok {
+		t.Fatal("ParseXMLLog() failed")
+	}
+	if !strings.HasPrefix(parsed.Message, "Order 42 failed\njava.lang.IllegalStateException: boom\n\tat ") {
+		t.Errorf("Message = %q, want throwable appended", parsed.Message)
+	}
+	if _, ok := parsed.ExtraFields["throwable"]; ok {
+		t.Error("appended throwable should not be an extra field")
+	}
+}
+
+func TestMultilineXMLRecord(t *testing.T) {
+	cfg := mustConfig(t, map[string]string{"parse-xml": "log4j"})
+	var collected collectedMessages
+	m := newMultilineMerger(cfg, collected.add)
+
+	m.AddLine([]byte("starting"), "stdout", 1000)
+	for i, line := range strings.Split(log4jEvent, "\n") {
+		m.AddLine([]byte(line), "stdout", int64(2000+i))
+	}
+	m.AddLine([]byte("after"), "stdout", 3000)
+	m.Flush()
+
+	msgs := collected.get()
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3: %q", len(msgs), msgs)
+	}
+	if string(msgs[1].Line) != log4jEvent {
+		t.Errorf("record = %q, want the full event", msgs[1].Line)
+	}
+	if msgs[1].TimeNano != 2000 {
+		t.Errorf("record time = %d, want time of first line", msgs[1].TimeNano)
+	}
+
+	// The record is emitted at the end tag, without waiting for a timeout
+	collected = collectedMessages{}
+	m = newMultilineMerger(cfg, collected.add)
+	m.AddLine([]byte(`<log4j:event level="INFO">`), "stdout", 1000)
+	m.AddLine([]byte(`<log4j:message>hi</log4j:message>`), "stdout", 1000)
+	m.AddLine([]byte(`</log4j:event>`), "stdout", 1000)
+	if got := collected.get(); len(got) != 1 {
+		t.Fatalf("got %d messages before flush, want 1", len(got))
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	_ "time/tzdata" // plugin rootfs has no zoneinfo, needed for timestamp-timezone
 
 	"github.com/baraverkstad/docker-journald-plus/driver"
 	"github.com/docker/go-plugins-helpers/sdk"